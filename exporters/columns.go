@@ -0,0 +1,121 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+type tableColumn[T any] struct {
+	Name  string
+	Value func(item T) interface{}
+}
+
+var foodEntryColumns = []tableColumn[fatsecret.FoodEntryData]{
+	{"date", func(e fatsecret.FoodEntryData) interface{} { return e.Date }},
+	{"date_int", func(e fatsecret.FoodEntryData) interface{} { return e.DateInt }},
+	{"food_entry_id", func(e fatsecret.FoodEntryData) interface{} { return e.FoodEntryId }},
+	{"food_id", func(e fatsecret.FoodEntryData) interface{} { return e.FoodId }},
+	{"serving_id", func(e fatsecret.FoodEntryData) interface{} { return e.ServingId }},
+	{"meal", func(e fatsecret.FoodEntryData) interface{} { return e.Meal }},
+	{"food_entry_name", func(e fatsecret.FoodEntryData) interface{} { return e.FoodEntryName }},
+	{"food_entry_description", func(e fatsecret.FoodEntryData) interface{} { return e.FoodEntryDescription }},
+	{"number_of_units", func(e fatsecret.FoodEntryData) interface{} { return e.NumberOfUnits }},
+	{"calories", func(e fatsecret.FoodEntryData) interface{} { return e.Calories }},
+	{"protein", func(e fatsecret.FoodEntryData) interface{} { return e.Protein }},
+	{"carbohydrate", func(e fatsecret.FoodEntryData) interface{} { return e.Carbohydrate }},
+	{"fat", func(e fatsecret.FoodEntryData) interface{} { return e.Fat }},
+	{"fiber", func(e fatsecret.FoodEntryData) interface{} { return e.Fiber }},
+	{"sugar", func(e fatsecret.FoodEntryData) interface{} { return e.Sugar }},
+	{"saturated_fat", func(e fatsecret.FoodEntryData) interface{} { return e.SaturatedFat }},
+	{"monounsaturated_fat", func(e fatsecret.FoodEntryData) interface{} { return e.MonounsaturatedFat }},
+	{"polyunsaturated_fat", func(e fatsecret.FoodEntryData) interface{} { return e.PolyunsaturatedFat }},
+	{"trans_fat", func(e fatsecret.FoodEntryData) interface{} { return e.TransFat }},
+	{"cholesterol", func(e fatsecret.FoodEntryData) interface{} { return e.Cholesterol }},
+	{"sodium", func(e fatsecret.FoodEntryData) interface{} { return e.Sodium }},
+	{"potassium", func(e fatsecret.FoodEntryData) interface{} { return e.Potassium }},
+	{"calcium", func(e fatsecret.FoodEntryData) interface{} { return e.Calcium }},
+	{"iron", func(e fatsecret.FoodEntryData) interface{} { return e.Iron }},
+	{"vitamin_a", func(e fatsecret.FoodEntryData) interface{} { return e.VitaminA }},
+	{"vitamin_c", func(e fatsecret.FoodEntryData) interface{} { return e.VitaminC }},
+	{"other_nutrients", func(e fatsecret.FoodEntryData) interface{} { return formatOtherNutrients(e) }},
+	{"barcode", func(e fatsecret.FoodEntryData) interface{} { return e.Barcode }},
+	{"nova_group", func(e fatsecret.FoodEntryData) interface{} { return formatNovaGroup(e) }},
+	{"ingredients", func(e fatsecret.FoodEntryData) interface{} { return e.Ingredients }},
+	{"fdc_id", func(e fatsecret.FoodEntryData) interface{} { return formatFdcId(e) }},
+}
+
+var foodEntryDayColumns = []tableColumn[fatsecret.FoodEntryDayData]{
+	{"date", func(d fatsecret.FoodEntryDayData) interface{} { return d.Date }},
+	{"date_int", func(d fatsecret.FoodEntryDayData) interface{} { return d.DateInt }},
+	{"calories", func(d fatsecret.FoodEntryDayData) interface{} { return d.Calories }},
+	{"protein", func(d fatsecret.FoodEntryDayData) interface{} { return d.Protein }},
+	{"carbohydrate", func(d fatsecret.FoodEntryDayData) interface{} { return d.Carbohydrate }},
+	{"fat", func(d fatsecret.FoodEntryDayData) interface{} { return d.Fat }},
+}
+
+var weightColumns = []tableColumn[fatsecret.WeightData]{
+	{"date", func(w fatsecret.WeightData) interface{} { return w.Date }},
+	{"date_int", func(w fatsecret.WeightData) interface{} { return w.DateInt }},
+	{"weight_kg", func(w fatsecret.WeightData) interface{} { return w.WeightKg }},
+	{"comment", func(w fatsecret.WeightData) interface{} { return w.Comment }},
+}
+
+var exerciseColumns = []tableColumn[fatsecret.ExerciseData]{
+	{"date", func(e fatsecret.ExerciseData) interface{} { return e.Date }},
+	{"date_int", func(e fatsecret.ExerciseData) interface{} { return e.DateInt }},
+	{"name", func(e fatsecret.ExerciseData) interface{} { return e.Name }},
+	{"duration_minutes", func(e fatsecret.ExerciseData) interface{} { return e.DurationMinutes }},
+	{"calories", func(e fatsecret.ExerciseData) interface{} { return e.Calories }},
+	{"steps", func(e fatsecret.ExerciseData) interface{} { return e.Steps }},
+	{"distance_km", func(e fatsecret.ExerciseData) interface{} { return e.DistanceKm }},
+}
+
+// Nutrients without their own column are kept as a JSON object, empty when there are none
+func formatOtherNutrients(entry fatsecret.FoodEntryData) string {
+	if len(entry.OtherNutrients) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(entry.OtherNutrients)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func formatNovaGroup(entry fatsecret.FoodEntryData) interface{} {
+	if entry.NovaGroup == 0 {
+		return ""
+	}
+	return int64(entry.NovaGroup)
+}
+
+func formatFdcId(entry fatsecret.FoodEntryData) interface{} {
+	if entry.FdcId == 0 {
+		return ""
+	}
+	return entry.FdcId
+}
+
+func selectColumns[T any](allColumns []tableColumn[T], columnNames []string) ([]tableColumn[T], error) {
+	if len(columnNames) == 0 {
+		return allColumns, nil
+	}
+
+	columnsByName := map[string]tableColumn[T]{}
+	for _, col := range allColumns {
+		columnsByName[col.Name] = col
+	}
+
+	var res []tableColumn[T]
+	for _, name := range columnNames {
+		col, ok := columnsByName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+		res = append(res, col)
+	}
+	return res, nil
+}
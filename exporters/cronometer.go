@@ -0,0 +1,83 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+var cronometerColumns = []migrationColumn{
+	{"Energy (kcal)", "Calories", func(e fatsecret.FoodEntryData) float64 { return e.Calories }},
+	{"Protein (g)", "Protein", func(e fatsecret.FoodEntryData) float64 { return e.Protein }},
+	{"Carbs (g)", "Carbohydrate", func(e fatsecret.FoodEntryData) float64 { return e.Carbohydrate }},
+	{"Fat (g)", "Fat", func(e fatsecret.FoodEntryData) float64 { return e.Fat }},
+	{"Fiber (g)", "Fiber", func(e fatsecret.FoodEntryData) float64 { return e.Fiber }},
+	{"Sugars (g)", "Sugar", func(e fatsecret.FoodEntryData) float64 { return e.Sugar }},
+	{"Saturated (g)", "SaturatedFat", func(e fatsecret.FoodEntryData) float64 { return e.SaturatedFat }},
+	{"Monounsaturated (g)", "MonounsaturatedFat", func(e fatsecret.FoodEntryData) float64 { return e.MonounsaturatedFat }},
+	{"Polyunsaturated (g)", "PolyunsaturatedFat", func(e fatsecret.FoodEntryData) float64 { return e.PolyunsaturatedFat }},
+	{"Trans-Fats (g)", "TransFat", func(e fatsecret.FoodEntryData) float64 { return e.TransFat }},
+	{"Cholesterol (mg)", "Cholesterol", func(e fatsecret.FoodEntryData) float64 { return e.Cholesterol }},
+	{"Sodium (mg)", "Sodium", func(e fatsecret.FoodEntryData) float64 { return e.Sodium }},
+	{"Potassium (mg)", "Potassium", func(e fatsecret.FoodEntryData) float64 { return e.Potassium }},
+	{"Calcium (mg)", "Calcium", func(e fatsecret.FoodEntryData) float64 { return e.Calcium * fatsecret.DailyValueCalciumMg / 100 }},
+	{"Iron (mg)", "Iron", func(e fatsecret.FoodEntryData) float64 { return e.Iron * fatsecret.DailyValueIronMg / 100 }},
+	{"Vitamin C (mg)", "VitaminC", func(e fatsecret.FoodEntryData) float64 { return e.VitaminC * fatsecret.DailyValueVitaminCMg / 100 }},
+}
+
+func WriteDiaryCronometerCsv(dir OutputDir, data *fatsecret.DiaryData) error {
+	return writeMigrationFiles(dir, "servings.csv", func(w io.Writer) ([]unmappedNutrient, error) {
+		return writeCronometerServingsCsv(w, data.DiaryData)
+	})
+}
+
+func writeCronometerServingsCsv(w io.Writer, entries []fatsecret.FoodEntryData) ([]unmappedNutrient, error) {
+	writer := csv.NewWriter(w)
+
+	header := []string{"Day", "Group", "Food Name", "Amount"}
+	for _, col := range cronometerColumns {
+		header = append(header, col.Name)
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, fmt.Errorf("Cronometer: error when writing header: %v", err)
+	}
+
+	var unmapped []unmappedNutrient
+	for _, entry := range entries {
+		row := []string{
+			entry.Date.Format(time.DateOnly),
+			getMigrationMeal(entry.Meal),
+			entry.FoodEntryName,
+			entry.FoodEntryDescription,
+		}
+		for _, col := range cronometerColumns {
+			val := ""
+			if !entry.IsNutrientMissing(col.Nutrient) {
+				val = formatMigrationValue(col.Value(entry))
+			}
+			row = append(row, val)
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, fmt.Errorf("Cronometer: error when writing row: %v", err)
+		}
+
+		unmapped = append(unmapped, getOtherNutrientsUnmapped(entry)...)
+		if !entry.IsNutrientMissing("VitaminA") && entry.VitaminA != 0 {
+			unmapped = append(unmapped, unmappedNutrient{
+				Entry:    entry,
+				Nutrient: "VitaminA",
+				Value:    entry.VitaminA,
+				Reason:   "% of daily value can't be converted to Cronometer's Vitamin A (µg RAE) without knowing the vitamin A source",
+			})
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("Cronometer: error when flushing data: %v", err)
+	}
+	return unmapped, nil
+}
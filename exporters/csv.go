@@ -0,0 +1,107 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+type CsvOptions struct {
+	EntryColumns     []string
+	DayColumns       []string
+	Delimiter        rune
+	DecimalSeparator string
+	Precision        int
+}
+
+func DefaultCsvOptions() CsvOptions {
+	return CsvOptions{
+		Delimiter:        ',',
+		DecimalSeparator: ".",
+		Precision:        -1,
+	}
+}
+
+func WriteDiaryCsv(dir OutputDir, data *fatsecret.DiaryData, opts CsvOptions) error {
+	if err := dir.WriteFile("entries.csv", func(w io.Writer) error {
+		return WriteFoodEntriesCsv(w, data.DiaryData, opts)
+	}); err != nil {
+		return err
+	}
+
+	if err := dir.WriteFile("days.csv", func(w io.Writer) error {
+		return WriteFoodEntryDaysCsv(w, data.AggregatedDayData, opts)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func WriteFoodEntriesCsv(w io.Writer, entries []fatsecret.FoodEntryData, opts CsvOptions) error {
+	return writeCsv(w, foodEntryColumns, opts.EntryColumns, entries, opts)
+}
+
+func WriteFoodEntryDaysCsv(w io.Writer, days []fatsecret.FoodEntryDayData, opts CsvOptions) error {
+	return writeCsv(w, foodEntryDayColumns, opts.DayColumns, days, opts)
+}
+
+func writeCsv[T any](w io.Writer, allColumns []tableColumn[T], columnNames []string, items []T, opts CsvOptions) error {
+	columns, err := selectColumns(allColumns, columnNames)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("CSV: error when writing header: %v", err)
+	}
+
+	row := make([]string, len(columns))
+	for _, item := range items {
+		for i, col := range columns {
+			row[i] = formatCsvValue(col.Value(item), opts)
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("CSV: error when writing row: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("CSV: error when flushing data: %v", err)
+	}
+	return nil
+}
+
+func formatCsvValue(val interface{}, opts CsvOptions) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		res := strconv.FormatFloat(v, 'f', opts.Precision, 64)
+		if opts.DecimalSeparator != "" && opts.DecimalSeparator != "." {
+			res = strings.Replace(res, ".", opts.DecimalSeparator, 1)
+		}
+		return res
+	case time.Time:
+		return v.Format(time.DateOnly)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,206 @@
+package exporters
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const loincSystem = "http://loinc.org"
+const ucumSystem = "http://unitsofmeasure.org"
+
+// Nutrients are coded with USDA nutrient numbers, the ones of FoodData Central and SR Legacy,
+// and with LOINC too when the nutrient has a LOINC coding
+const usdaNutrientSystem = "https://fdc.nal.usda.gov/nutrient-number"
+
+type FhirOptions struct {
+	Subject string
+}
+
+type fhirBundle struct {
+	ResourceType string            `json:"resourceType"`
+	Type         string            `json:"type"`
+	Timestamp    string            `json:"timestamp"`
+	Entry        []fhirBundleEntry `json:"entry"`
+}
+
+type fhirBundleEntry struct {
+	FullUrl  string          `json:"fullUrl"`
+	Resource fhirObservation `json:"resource"`
+}
+
+type fhirObservation struct {
+	ResourceType      string                     `json:"resourceType"`
+	Id                string                     `json:"id"`
+	Status            string                     `json:"status"`
+	Code              fhirCodeableConcept        `json:"code"`
+	Subject           *fhirReference             `json:"subject,omitempty"`
+	EffectiveDateTime string                     `json:"effectiveDateTime"`
+	ValueQuantity     *fhirQuantity              `json:"valueQuantity,omitempty"`
+	Note              []fhirAnnotation           `json:"note,omitempty"`
+	Component         []fhirObservationComponent `json:"component,omitempty"`
+}
+
+type fhirObservationComponent struct {
+	Code          fhirCodeableConcept `json:"code"`
+	ValueQuantity fhirQuantity        `json:"valueQuantity"`
+}
+
+type fhirCodeableConcept struct {
+	Coding []fhirCoding `json:"coding,omitempty"`
+	Text   string       `json:"text,omitempty"`
+}
+
+type fhirCoding struct {
+	System  string `json:"system"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type fhirQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+	System string  `json:"system"`
+	Code   string  `json:"code"`
+}
+
+type fhirReference struct {
+	Reference string `json:"reference"`
+}
+
+type fhirAnnotation struct {
+	Text string `json:"text"`
+}
+
+type fhirNutrient struct {
+	Name       string
+	Display    string
+	Loinc      *fhirCoding
+	UsdaNumber string
+	Unit       string
+	Value      func(e fatsecret.FoodEntryData) float64
+}
+
+// 9052-2 is a daily total, so it codes the day observations only and not the energy of a single food entry
+var fhirEnergyCoding = fhirCoding{System: loincSystem, Code: "9052-2", Display: "Calorie intake total"}
+
+var fhirNutrients = []fhirNutrient{
+	{"Calories", "Energy", nil, "208", "kcal", func(e fatsecret.FoodEntryData) float64 { return e.Calories }},
+	{"Protein", "Protein", nil, "203", "g", func(e fatsecret.FoodEntryData) float64 { return e.Protein }},
+	{"Carbohydrate", "Carbohydrate, by difference", nil, "205", "g", func(e fatsecret.FoodEntryData) float64 { return e.Carbohydrate }},
+	{"Fat", "Total lipid (fat)", nil, "204", "g", func(e fatsecret.FoodEntryData) float64 { return e.Fat }},
+	{"SaturatedFat", "Fatty acids, total saturated", nil, "606", "g", func(e fatsecret.FoodEntryData) float64 { return e.SaturatedFat }},
+	{"Fiber", "Fiber, total dietary", nil, "291", "g", func(e fatsecret.FoodEntryData) float64 { return e.Fiber }},
+	{"Sugar", "Sugars, total", nil, "269", "g", func(e fatsecret.FoodEntryData) float64 { return e.Sugar }},
+	{"Cholesterol", "Cholesterol", nil, "601", "mg", func(e fatsecret.FoodEntryData) float64 { return e.Cholesterol }},
+	{"Sodium", "Sodium, Na", nil, "307", "mg", func(e fatsecret.FoodEntryData) float64 { return e.Sodium }},
+	{"Potassium", "Potassium, K", nil, "306", "mg", func(e fatsecret.FoodEntryData) float64 { return e.Potassium }},
+}
+
+func WriteDiaryFhirBundle(w io.Writer, data *fatsecret.DiaryData, opts FhirOptions) error {
+	bundle := fhirBundle{
+		ResourceType: "Bundle",
+		Type:         "collection",
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var subject *fhirReference
+	if opts.Subject != "" {
+		subject = &fhirReference{Reference: opts.Subject}
+	}
+
+	for _, day := range data.AggregatedDayData {
+		entry := fatsecret.FoodEntryData{
+			Calories:     day.Calories,
+			Protein:      day.Protein,
+			Carbohydrate: day.Carbohydrate,
+			Fat:          day.Fat,
+		}
+		calories := getFhirQuantity(fhirNutrients[0], entry)
+		observation := fhirObservation{
+			ResourceType:      "Observation",
+			Id:                fmt.Sprintf("day-%d", day.DateInt),
+			Status:            "final",
+			Code:              fhirCodeableConcept{Coding: []fhirCoding{fhirEnergyCoding}, Text: "Daily nutrition intake"},
+			Subject:           subject,
+			EffectiveDateTime: day.Date.Format(time.DateOnly),
+			ValueQuantity:     &calories,
+			Component:         getFhirComponents(fhirNutrients[1:4], entry),
+		}
+		bundle.Entry = append(bundle.Entry, newFhirBundleEntry(observation))
+	}
+
+	for _, entry := range data.DiaryData {
+		observation := fhirObservation{
+			ResourceType:      "Observation",
+			Id:                fmt.Sprintf("food-entry-%d", entry.FoodEntryId),
+			Status:            "final",
+			Code:              fhirCodeableConcept{Text: entry.FoodEntryName},
+			Subject:           subject,
+			EffectiveDateTime: entry.Date.Format(time.DateOnly),
+			Component:         getFhirComponents(fhirNutrients, entry),
+		}
+		if note := strings.TrimSpace(entry.Meal + ": " + entry.FoodEntryDescription); note != ":" {
+			observation.Note = []fhirAnnotation{{Text: note}}
+		}
+		bundle.Entry = append(bundle.Entry, newFhirBundleEntry(observation))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return fmt.Errorf("FHIR: error when writing bundle: %v", err)
+	}
+	return nil
+}
+
+func newFhirBundleEntry(observation fhirObservation) fhirBundleEntry {
+	return fhirBundleEntry{
+		FullUrl:  "urn:uuid:" + nameBasedUuid("Observation/"+observation.Id),
+		Resource: observation,
+	}
+}
+
+func getFhirComponents(nutrients []fhirNutrient, entry fatsecret.FoodEntryData) []fhirObservationComponent {
+	var res []fhirObservationComponent
+	for _, nutrient := range nutrients {
+		if entry.IsNutrientMissing(nutrient.Name) {
+			continue
+		}
+
+		code := fhirCodeableConcept{
+			Coding: []fhirCoding{{System: usdaNutrientSystem, Code: nutrient.UsdaNumber, Display: nutrient.Display}},
+			Text:   nutrient.Display,
+		}
+		if nutrient.Loinc != nil {
+			code.Coding = append([]fhirCoding{*nutrient.Loinc}, code.Coding...)
+		}
+
+		res = append(res, fhirObservationComponent{
+			Code:          code,
+			ValueQuantity: getFhirQuantity(nutrient, entry),
+		})
+	}
+	return res
+}
+
+func getFhirQuantity(nutrient fhirNutrient, entry fatsecret.FoodEntryData) fhirQuantity {
+	return fhirQuantity{
+		Value:  nutrient.Value(entry),
+		Unit:   nutrient.Unit,
+		System: ucumSystem,
+		Code:   nutrient.Unit,
+	}
+}
+
+func nameBasedUuid(name string) string {
+	hash := sha1.Sum([]byte("data-migrators487:" + name))
+	hash[6] = (hash[6] & 0x0f) | 0x50
+	hash[8] = (hash[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:16])
+}
@@ -0,0 +1,123 @@
+package exporters
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+func getTestDiary() *fatsecret.DiaryData {
+	date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	return &fatsecret.DiaryData{
+		FromDate: date,
+		ToDate:   date,
+		AggregatedDayData: []fatsecret.FoodEntryDayData{
+			{DateInt: 19787, Date: date, Calories: 1850, Carbohydrate: 210.5, Fat: 61, Protein: 95.2},
+		},
+		DiaryData: []fatsecret.FoodEntryData{
+			{
+				DateInt: 19787, Date: date, FoodId: 1, FoodEntryId: 10, FoodEntryName: "Oatmeal",
+				FoodEntryDescription: "1 cup", NumberOfUnits: 1, Meal: "Breakfast",
+				Calories: 158, Protein: 6, Carbohydrate: 27, Fat: 3.2, Fiber: 4, Sodium: 115,
+				MissingNutrients: []string{"Sugar"},
+			},
+			{DateInt: 19787, Date: date, FoodId: 2, FoodEntryId: 11, FoodEntryName: "Apple", Meal: "Other", Calories: 95},
+		},
+		Weights: []fatsecret.WeightData{
+			{DateInt: 19787, Date: date.Add(7 * time.Hour), WeightKg: 72.4},
+		},
+		Exercises: []fatsecret.ExerciseData{
+			{DateInt: 19787, Date: date.Add(18 * time.Hour), Name: "Running", DurationMinutes: 30, Calories: 320, DistanceKm: 5.1},
+			{DateInt: 19787, Date: date.Add(20 * time.Hour), Name: "Walking", DurationMinutes: 45},
+		},
+	}
+}
+
+func TestWriteDiaryFhirBundleStructure(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiaryFhirBundle(&buf, getTestDiary(), FhirOptions{Subject: "Patient/123"}); err != nil {
+		t.Fatal(err)
+	}
+	var bundle fhirBundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.ResourceType != "Bundle" || bundle.Type != "collection" {
+		t.Errorf("unexpected bundle %s %s", bundle.ResourceType, bundle.Type)
+	}
+	if _, err := time.Parse(time.RFC3339, bundle.Timestamp); err != nil {
+		t.Errorf("unexpected timestamp: %v", err)
+	}
+
+	urls := map[string]bool{}
+	for _, entry := range bundle.Entry {
+		if !strings.HasPrefix(entry.FullUrl, "urn:uuid:") || urls[entry.FullUrl] {
+			t.Errorf("unexpected or duplicate url %s", entry.FullUrl)
+		}
+		urls[entry.FullUrl] = true
+
+		res := entry.Resource
+		if res.ResourceType != "Observation" || res.Status != "final" || res.Id == "" {
+			t.Errorf("unexpected observation %+v", res)
+		}
+		if res.Subject == nil || res.Subject.Reference != "Patient/123" {
+			t.Errorf("unexpected subject of %s: %v", res.Id, res.Subject)
+		}
+		if res.EffectiveDateTime != "2024-03-05" {
+			t.Errorf("unexpected effective date of %s: %s", res.Id, res.EffectiveDateTime)
+		}
+		for _, component := range res.Component {
+			if len(component.Code.Coding) == 0 || component.ValueQuantity.Code == "" {
+				t.Errorf("uncoded component %+v of %s", component, res.Id)
+			}
+		}
+	}
+}
+
+func TestWriteDiaryFhirBundleCodes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDiaryFhirBundle(&buf, getTestDiary(), FhirOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var bundle fhirBundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Entry) != 3 {
+		t.Fatalf("expected 3 observations, got %d", len(bundle.Entry))
+	}
+
+	day := bundle.Entry[0].Resource
+	if day.Code.Coding[0].System != loincSystem || day.Code.Coding[0].Code != "9052-2" {
+		t.Errorf("unexpected day code %+v", day.Code)
+	}
+	if day.ValueQuantity == nil || day.ValueQuantity.Value != 1850 || day.ValueQuantity.Code != "kcal" {
+		t.Errorf("unexpected day value %+v", day.ValueQuantity)
+	}
+
+	codes := map[string]fhirCoding{}
+	for _, component := range bundle.Entry[1].Resource.Component {
+		for _, coding := range component.Code.Coding {
+			if coding.System == usdaNutrientSystem {
+				codes[coding.Code] = coding
+			} else if coding.Code == fhirEnergyCoding.Code {
+				t.Errorf("food entry energy is coded as a daily total")
+			}
+		}
+		if component.ValueQuantity.System != ucumSystem {
+			t.Errorf("unexpected unit system of %s", component.Code.Text)
+		}
+	}
+	for _, code := range []string{"208", "203", "205", "204", "291", "307"} {
+		if _, ok := codes[code]; !ok {
+			t.Errorf("there is no USDA nutrient %s in %v", code, codes)
+		}
+	}
+	if _, ok := codes["269"]; ok {
+		t.Errorf("missing sugar is written")
+	}
+}
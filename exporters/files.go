@@ -0,0 +1,134 @@
+package exporters
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const StdoutPath = "-"
+
+type Compression string
+
+const (
+	NoCompression   Compression = ""
+	GzipCompression Compression = "gzip"
+	ZstdCompression Compression = "zstd"
+)
+
+var Compressions = []string{string(GzipCompression), string(ZstdCompression)}
+
+func CompressionFromPath(filePath string) Compression {
+	switch {
+	case strings.HasSuffix(filePath, ".gz"):
+		return GzipCompression
+	case strings.HasSuffix(filePath, ".zst"):
+		return ZstdCompression
+	default:
+		return NoCompression
+	}
+}
+
+func (c Compression) Ext() string {
+	switch c {
+	case GzipCompression:
+		return ".gz"
+	case ZstdCompression:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+type OutputDir struct {
+	Path        string
+	Compression Compression
+}
+
+func (d OutputDir) WriteFile(name string, write func(w io.Writer) error) error {
+	return WriteFile(path.Join(d.Path, name+d.Compression.Ext()), d.Compression, write)
+}
+
+func WriteFile(filePath string, compression Compression, write func(w io.Writer) error) error {
+	if filePath == StdoutPath {
+		bw := bufio.NewWriter(os.Stdout)
+		if err := writeCompressed(bw, compression, write); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("error when writing to stdout: %v", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("error when creating a directory: %v", err)
+	}
+
+	fp, err := os.CreateTemp(path.Dir(filePath), "."+path.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error when creating a temporary file: %v", err)
+	}
+	tmpPath := fp.Name()
+	defer func() {
+		if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: error when removing a temporary file: %v", err)
+		}
+	}()
+
+	bw := bufio.NewWriter(fp)
+	err = writeCompressed(bw, compression, write)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = fp.Chmod(0644)
+	}
+	if err == nil {
+		err = fp.Sync()
+	}
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error when writing file %s: %v", filePath, err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("error when moving file to %s: %v", filePath, err)
+	}
+	return nil
+}
+
+func writeCompressed(w io.Writer, compression Compression, write func(w io.Writer) error) error {
+	var cw io.WriteCloser
+	switch compression {
+	case NoCompression:
+		return write(w)
+	case GzipCompression:
+		cw = gzip.NewWriter(w)
+	case ZstdCompression:
+		var err error
+		if cw, err = zstd.NewWriter(w); err != nil {
+			return fmt.Errorf("error when creating zstd writer: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown compression %s", compression)
+	}
+
+	if err := write(cw); err != nil {
+		_ = cw.Close()
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("error when finishing %s stream: %v", compression, err)
+	}
+	return nil
+}
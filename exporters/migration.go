@@ -0,0 +1,110 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const unmappedNutrientsFile = "unmapped-nutrients.csv"
+
+type unmappedNutrient struct {
+	Entry    fatsecret.FoodEntryData
+	Nutrient string
+	Value    float64
+	Reason   string
+}
+
+type migrationColumn struct {
+	Name     string
+	Nutrient string
+	Value    func(e fatsecret.FoodEntryData) float64
+}
+
+func getMigrationMeal(meal string) string {
+	switch strings.ToLower(meal) {
+	case "breakfast":
+		return "Breakfast"
+	case "lunch":
+		return "Lunch"
+	case "dinner":
+		return "Dinner"
+	default:
+		return "Snacks"
+	}
+}
+
+func formatMigrationValue(val float64) string {
+	return strconv.FormatFloat(math.Round(val*100)/100, 'f', -1, 64)
+}
+
+func getOtherNutrientsUnmapped(entry fatsecret.FoodEntryData) []unmappedNutrient {
+	var res []unmappedNutrient
+	for _, name := range sortedFloatKeys(entry.OtherNutrients) {
+		res = append(res, unmappedNutrient{
+			Entry:    entry,
+			Nutrient: name,
+			Value:    entry.OtherNutrients[name],
+			Reason:   "the target app has no column for this nutrient",
+		})
+	}
+	return res
+}
+
+func sortedFloatKeys(vals map[string]float64) []string {
+	res := make([]string, 0, len(vals))
+	for key := range vals {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func writeMigrationFiles(dir OutputDir, fileName string, write func(w io.Writer) ([]unmappedNutrient, error)) error {
+	var unmapped []unmappedNutrient
+	if err := dir.WriteFile(fileName, func(w io.Writer) error {
+		var err error
+		unmapped, err = write(w)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return dir.WriteFile(unmappedNutrientsFile, func(w io.Writer) error {
+		return writeUnmappedNutrientsCsv(w, unmapped)
+	})
+}
+
+func writeUnmappedNutrientsCsv(w io.Writer, unmapped []unmappedNutrient) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"date", "meal", "food_entry_id", "food_entry_name", "nutrient", "value", "reason"}); err != nil {
+		return fmt.Errorf("CSV: error when writing unmapped nutrients header: %v", err)
+	}
+
+	for _, item := range unmapped {
+		if err := writer.Write([]string{
+			item.Entry.Date.Format(time.DateOnly),
+			item.Entry.Meal,
+			strconv.FormatInt(item.Entry.FoodEntryId, 10),
+			item.Entry.FoodEntryName,
+			item.Nutrient,
+			strconv.FormatFloat(item.Value, 'f', -1, 64),
+			item.Reason,
+		}); err != nil {
+			return fmt.Errorf("CSV: error when writing unmapped nutrient: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("CSV: error when flushing unmapped nutrients: %v", err)
+	}
+	return nil
+}
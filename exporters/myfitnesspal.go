@@ -0,0 +1,130 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+var myFitnessPalMeals = []string{"Breakfast", "Lunch", "Dinner", "Snacks"}
+
+var myFitnessPalColumns = []migrationColumn{
+	{"Calories", "Calories", func(e fatsecret.FoodEntryData) float64 { return e.Calories }},
+	{"Fat (g)", "Fat", func(e fatsecret.FoodEntryData) float64 { return e.Fat }},
+	{"Saturated Fat", "SaturatedFat", func(e fatsecret.FoodEntryData) float64 { return e.SaturatedFat }},
+	{"Polyunsaturated Fat", "PolyunsaturatedFat", func(e fatsecret.FoodEntryData) float64 { return e.PolyunsaturatedFat }},
+	{"Monounsaturated Fat", "MonounsaturatedFat", func(e fatsecret.FoodEntryData) float64 { return e.MonounsaturatedFat }},
+	{"Trans Fat", "TransFat", func(e fatsecret.FoodEntryData) float64 { return e.TransFat }},
+	{"Cholesterol", "Cholesterol", func(e fatsecret.FoodEntryData) float64 { return e.Cholesterol }},
+	{"Sodium (mg)", "Sodium", func(e fatsecret.FoodEntryData) float64 { return e.Sodium }},
+	{"Potassium", "Potassium", func(e fatsecret.FoodEntryData) float64 { return e.Potassium }},
+	{"Carbohydrates (g)", "Carbohydrate", func(e fatsecret.FoodEntryData) float64 { return e.Carbohydrate }},
+	{"Fiber", "Fiber", func(e fatsecret.FoodEntryData) float64 { return e.Fiber }},
+	{"Sugar", "Sugar", func(e fatsecret.FoodEntryData) float64 { return e.Sugar }},
+	{"Protein (g)", "Protein", func(e fatsecret.FoodEntryData) float64 { return e.Protein }},
+	{"Vitamin A", "VitaminA", func(e fatsecret.FoodEntryData) float64 { return e.VitaminA }},
+	{"Vitamin C", "VitaminC", func(e fatsecret.FoodEntryData) float64 { return e.VitaminC }},
+	{"Calcium", "Calcium", func(e fatsecret.FoodEntryData) float64 { return e.Calcium }},
+	{"Iron", "Iron", func(e fatsecret.FoodEntryData) float64 { return e.Iron }},
+}
+
+type myFitnessPalMeal struct {
+	Date      time.Time
+	Meal      string
+	Values    []float64
+	Present   []bool
+	FoodNames []string
+}
+
+func WriteDiaryMyFitnessPalCsv(dir OutputDir, data *fatsecret.DiaryData) error {
+	return writeMigrationFiles(dir, "nutrition.csv", func(w io.Writer) ([]unmappedNutrient, error) {
+		return writeMyFitnessPalNutritionCsv(w, data.DiaryData)
+	})
+}
+
+func writeMyFitnessPalNutritionCsv(w io.Writer, entries []fatsecret.FoodEntryData) ([]unmappedNutrient, error) {
+	writer := csv.NewWriter(w)
+
+	header := []string{"Date", "Meal"}
+	for _, col := range myFitnessPalColumns {
+		header = append(header, col.Name)
+	}
+	header = append(header, "Note")
+	if err := writer.Write(header); err != nil {
+		return nil, fmt.Errorf("MyFitnessPal: error when writing header: %v", err)
+	}
+
+	var unmapped []unmappedNutrient
+	for _, entry := range entries {
+		unmapped = append(unmapped, getOtherNutrientsUnmapped(entry)...)
+	}
+
+	for _, meal := range getMyFitnessPalMeals(entries) {
+		row := []string{meal.Date.Format(time.DateOnly), meal.Meal}
+		for i := range myFitnessPalColumns {
+			val := ""
+			if meal.Present[i] {
+				val = formatMigrationValue(meal.Values[i])
+			}
+			row = append(row, val)
+		}
+		row = append(row, strings.Join(meal.FoodNames, "; "))
+
+		if err := writer.Write(row); err != nil {
+			return nil, fmt.Errorf("MyFitnessPal: error when writing row: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("MyFitnessPal: error when flushing data: %v", err)
+	}
+	return unmapped, nil
+}
+
+func getMyFitnessPalMeals(entries []fatsecret.FoodEntryData) []*myFitnessPalMeal {
+	var dates []int64
+	meals := map[int64]map[string]*myFitnessPalMeal{}
+	for _, entry := range entries {
+		dayMeals, ok := meals[entry.DateInt]
+		if !ok {
+			dayMeals = map[string]*myFitnessPalMeal{}
+			meals[entry.DateInt] = dayMeals
+			dates = append(dates, entry.DateInt)
+		}
+
+		mealName := getMigrationMeal(entry.Meal)
+		meal, ok := dayMeals[mealName]
+		if !ok {
+			meal = &myFitnessPalMeal{
+				Date:    entry.Date,
+				Meal:    mealName,
+				Values:  make([]float64, len(myFitnessPalColumns)),
+				Present: make([]bool, len(myFitnessPalColumns)),
+			}
+			dayMeals[mealName] = meal
+		}
+
+		for i, col := range myFitnessPalColumns {
+			if !entry.IsNutrientMissing(col.Nutrient) {
+				meal.Values[i] += col.Value(entry)
+				meal.Present[i] = true
+			}
+		}
+		meal.FoodNames = append(meal.FoodNames, entry.FoodEntryName)
+	}
+
+	var res []*myFitnessPalMeal
+	for _, dateInt := range dates {
+		for _, mealName := range myFitnessPalMeals {
+			if meal, ok := meals[dateInt][mealName]; ok {
+				res = append(res, meal)
+			}
+		}
+	}
+	return res
+}
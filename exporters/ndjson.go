@@ -0,0 +1,54 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const NdjsonDayType = "day"
+const NdjsonEntryType = "entry"
+const NdjsonWeightType = "weight"
+const NdjsonExerciseType = "exercise"
+
+type NdjsonWriter struct {
+	encoder *json.Encoder
+}
+
+type ndjsonLine struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+func NewNdjsonWriter(w io.Writer) *NdjsonWriter {
+	return &NdjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+func (n *NdjsonWriter) ConsumeDay(day fatsecret.FoodEntryDayData) error {
+	return n.writeLine(NdjsonDayType, day)
+}
+
+func (n *NdjsonWriter) ConsumeEntry(entry fatsecret.FoodEntryData) error {
+	return n.writeLine(NdjsonEntryType, entry)
+}
+
+func (n *NdjsonWriter) ConsumeWeight(weight fatsecret.WeightData) error {
+	return n.writeLine(NdjsonWeightType, weight)
+}
+
+func (n *NdjsonWriter) ConsumeExercise(exercise fatsecret.ExerciseData) error {
+	return n.writeLine(NdjsonExerciseType, exercise)
+}
+
+func (n *NdjsonWriter) writeLine(lineType string, data interface{}) error {
+	if err := n.encoder.Encode(ndjsonLine{Type: lineType, Data: data}); err != nil {
+		return fmt.Errorf("NDJSON: error when writing %s line: %v", lineType, err)
+	}
+	return nil
+}
+
+func WriteDiaryNdjson(w io.Writer, data *fatsecret.DiaryData) error {
+	return data.Stream(NewNdjsonWriter(w))
+}
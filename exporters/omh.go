@@ -0,0 +1,154 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const omhSourceName = "data-migrators487"
+
+type omhDataPoint struct {
+	Header omhHeader   `json:"header"`
+	Body   interface{} `json:"body"`
+}
+
+type omhHeader struct {
+	Id                    string                   `json:"id"`
+	CreationDateTime      string                   `json:"creation_date_time"`
+	SchemaId              omhSchemaId              `json:"schema_id"`
+	AcquisitionProvenance omhAcquisitionProvenance `json:"acquisition_provenance"`
+}
+
+type omhSchemaId struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+}
+
+type omhAcquisitionProvenance struct {
+	SourceName string `json:"source_name"`
+	Modality   string `json:"modality"`
+}
+
+type omhUnitValue struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+type omhTimeFrame struct {
+	DateTime     string           `json:"date_time,omitempty"`
+	TimeInterval *omhTimeInterval `json:"time_interval,omitempty"`
+}
+
+type omhTimeInterval struct {
+	StartDateTime string        `json:"start_date_time"`
+	EndDateTime   string        `json:"end_date_time,omitempty"`
+	Duration      *omhUnitValue `json:"duration,omitempty"`
+}
+
+type omhNutritionIntakeBody struct {
+	EffectiveTimeFrame omhTimeFrame            `json:"effective_time_frame"`
+	FoodName           string                  `json:"food_name,omitempty"`
+	FoodDescription    string                  `json:"food_description,omitempty"`
+	Meal               string                  `json:"meal,omitempty"`
+	Nutrients          map[string]omhUnitValue `json:"nutrients"`
+}
+
+type omhBodyWeightBody struct {
+	BodyWeight         omhUnitValue `json:"body_weight"`
+	EffectiveTimeFrame omhTimeFrame `json:"effective_time_frame"`
+}
+
+type omhPhysicalActivityBody struct {
+	ActivityName       string        `json:"activity_name"`
+	EffectiveTimeFrame omhTimeFrame  `json:"effective_time_frame"`
+	Distance           *omhUnitValue `json:"distance,omitempty"`
+	KcalBurned         *omhUnitValue `json:"kcal_burned,omitempty"`
+}
+
+var omhNutritionIntakeSchema = omhSchemaId{Namespace: "dm487", Name: "nutrition-intake", Version: "1.0"}
+var omhBodyWeightSchema = omhSchemaId{Namespace: "omh", Name: "body-weight", Version: "2.0"}
+var omhPhysicalActivitySchema = omhSchemaId{Namespace: "omh", Name: "physical-activity", Version: "1.2"}
+
+func WriteDiaryOpenMHealth(w io.Writer, data *fatsecret.DiaryData) error {
+	creationTime := time.Now().UTC().Format(time.RFC3339)
+	newDataPoint := func(id string, schemaId omhSchemaId, body interface{}) omhDataPoint {
+		return omhDataPoint{
+			Header: omhHeader{
+				Id:                    nameBasedUuid(id),
+				CreationDateTime:      creationTime,
+				SchemaId:              schemaId,
+				AcquisitionProvenance: omhAcquisitionProvenance{SourceName: omhSourceName, Modality: "self-reported"},
+			},
+			Body: body,
+		}
+	}
+
+	res := []omhDataPoint{}
+	for _, entry := range data.DiaryData {
+		nutrients := map[string]omhUnitValue{}
+		for _, nutrient := range fhirNutrients {
+			if !entry.IsNutrientMissing(nutrient.Name) {
+				nutrients[nutrient.Name] = omhUnitValue{Value: nutrient.Value(entry), Unit: nutrient.Unit}
+			}
+		}
+
+		res = append(res, newDataPoint(
+			fmt.Sprintf("food-entry-%d", entry.FoodEntryId),
+			omhNutritionIntakeSchema,
+			omhNutritionIntakeBody{
+				EffectiveTimeFrame: omhDayTimeFrame(entry.Date),
+				FoodName:           entry.FoodEntryName,
+				FoodDescription:    entry.FoodEntryDescription,
+				Meal:               entry.Meal,
+				Nutrients:          nutrients,
+			},
+		))
+	}
+
+	for i, weight := range data.Weights {
+		res = append(res, newDataPoint(
+			fmt.Sprintf("weight-%d-%d", weight.DateInt, i),
+			omhBodyWeightSchema,
+			omhBodyWeightBody{
+				BodyWeight:         omhUnitValue{Value: weight.WeightKg, Unit: "kg"},
+				EffectiveTimeFrame: omhTimeFrame{DateTime: weight.Date.UTC().Format(time.RFC3339)},
+			},
+		))
+	}
+
+	for i, exercise := range data.Exercises {
+		body := omhPhysicalActivityBody{
+			ActivityName: exercise.Name,
+			EffectiveTimeFrame: omhTimeFrame{TimeInterval: &omhTimeInterval{
+				StartDateTime: exercise.Date.UTC().Format(time.RFC3339),
+				Duration:      &omhUnitValue{Value: exercise.DurationMinutes, Unit: "min"},
+			}},
+		}
+		if exercise.DistanceKm > 0 {
+			body.Distance = &omhUnitValue{Value: exercise.DistanceKm, Unit: "km"}
+		}
+		if exercise.Calories > 0 {
+			body.KcalBurned = &omhUnitValue{Value: exercise.Calories, Unit: "kcal"}
+		}
+		res = append(res, newDataPoint(fmt.Sprintf("exercise-%d-%d", exercise.DateInt, i), omhPhysicalActivitySchema, body))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(res); err != nil {
+		return fmt.Errorf("Open mHealth: error when writing data points: %v", err)
+	}
+	return nil
+}
+
+func omhDayTimeFrame(date time.Time) omhTimeFrame {
+	return omhTimeFrame{TimeInterval: &omhTimeInterval{
+		StartDateTime: date.UTC().Format(time.RFC3339),
+		EndDateTime:   date.UTC().AddDate(0, 0, 1).Format(time.RFC3339),
+	}}
+}
@@ -0,0 +1,61 @@
+package exporters
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteDiaryOpenMHealth(t *testing.T) {
+	diary := getTestDiary()
+	second := diary.Weights[0]
+	second.Date = second.Date.Add(12 * time.Hour)
+	second.WeightKg = 72.9
+	diary.Weights = append(diary.Weights, second)
+
+	var buf bytes.Buffer
+	if err := WriteDiaryOpenMHealth(&buf, diary); err != nil {
+		t.Fatal(err)
+	}
+	var dataPoints []struct {
+		Header omhHeader                  `json:"header"`
+		Body   map[string]json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &dataPoints); err != nil {
+		t.Fatal(err)
+	}
+	if len(dataPoints) != 6 {
+		t.Fatalf("expected 6 data points, got %d", len(dataPoints))
+	}
+
+	ids := map[string]bool{}
+	schemas := map[string]int{}
+	for _, dataPoint := range dataPoints {
+		header := dataPoint.Header
+		if header.Id == "" || ids[header.Id] {
+			t.Errorf("empty or duplicate id %q", header.Id)
+		}
+		ids[header.Id] = true
+		schemas[header.SchemaId.Namespace+":"+header.SchemaId.Name]++
+
+		if _, ok := dataPoint.Body["effective_time_frame"]; !ok {
+			t.Errorf("%s has no effective time frame", header.Id)
+		}
+		if header.AcquisitionProvenance.SourceName != omhSourceName {
+			t.Errorf("unexpected provenance %+v", header.AcquisitionProvenance)
+		}
+	}
+
+	expected := map[string]int{"dm487:nutrition-intake": 2, "omh:body-weight": 2, "omh:physical-activity": 2}
+	for schema, count := range expected {
+		if schemas[schema] != count {
+			t.Errorf("expected %d %s data points, got %d", count, schema, schemas[schema])
+		}
+	}
+
+	var weight omhUnitValue
+	if err := json.Unmarshal(dataPoints[3].Body["body_weight"], &weight); err != nil || weight.Value != 72.9 || weight.Unit != "kg" {
+		t.Errorf("unexpected body weight %+v: %v", weight, err)
+	}
+}
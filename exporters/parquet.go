@@ -0,0 +1,172 @@
+package exporters
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+type ParquetOptions struct {
+	PartitionByMonth bool
+}
+
+type foodEntryParquetRow struct {
+	Date                 int32    `parquet:"date,date"`
+	FoodEntryId          int64    `parquet:"food_entry_id"`
+	FoodId               int64    `parquet:"food_id"`
+	ServingId            int64    `parquet:"serving_id"`
+	Meal                 string   `parquet:"meal,dict"`
+	FoodEntryName        string   `parquet:"food_entry_name"`
+	FoodEntryDescription string   `parquet:"food_entry_description"`
+	NumberOfUnits        float64  `parquet:"number_of_units"`
+	Calories             *float64 `parquet:"calories,optional"`
+	Protein              *float64 `parquet:"protein,optional"`
+	Carbohydrate         *float64 `parquet:"carbohydrate,optional"`
+	Fat                  *float64 `parquet:"fat,optional"`
+	Fiber                *float64 `parquet:"fiber,optional"`
+	Sugar                *float64 `parquet:"sugar,optional"`
+	SaturatedFat         *float64 `parquet:"saturated_fat,optional"`
+	MonounsaturatedFat   *float64 `parquet:"monounsaturated_fat,optional"`
+	PolyunsaturatedFat   *float64 `parquet:"polyunsaturated_fat,optional"`
+	TransFat             *float64 `parquet:"trans_fat,optional"`
+	Cholesterol          *float64 `parquet:"cholesterol,optional"`
+	Sodium               *float64 `parquet:"sodium,optional"`
+	Potassium            *float64 `parquet:"potassium,optional"`
+	Calcium              *float64 `parquet:"calcium,optional"`
+	Iron                 *float64 `parquet:"iron,optional"`
+	VitaminA             *float64 `parquet:"vitamin_a,optional"`
+	VitaminC             *float64 `parquet:"vitamin_c,optional"`
+	OtherNutrients       *string  `parquet:"other_nutrients,optional"`
+	Barcode              *string  `parquet:"barcode,optional"`
+	NovaGroup            *int32   `parquet:"nova_group,optional"`
+	Ingredients          *string  `parquet:"ingredients,optional"`
+	FdcId                *int64   `parquet:"fdc_id,optional"`
+}
+
+type foodEntryDayParquetRow struct {
+	Date         int32   `parquet:"date,date"`
+	Calories     float64 `parquet:"calories"`
+	Protein      float64 `parquet:"protein"`
+	Carbohydrate float64 `parquet:"carbohydrate"`
+	Fat          float64 `parquet:"fat"`
+}
+
+func WriteDiaryParquet(dir OutputDir, data *fatsecret.DiaryData, opts ParquetOptions) error {
+	entryPartitions := map[string][]fatsecret.FoodEntryData{}
+	for _, entry := range data.DiaryData {
+		name := parquetPartitionName("entries", entry.Date.Year(), int(entry.Date.Month()), opts)
+		entryPartitions[name] = append(entryPartitions[name], entry)
+	}
+	for name, entries := range entryPartitions {
+		if err := dir.WriteFile(name, func(w io.Writer) error {
+			return WriteFoodEntriesParquet(w, entries)
+		}); err != nil {
+			return err
+		}
+	}
+
+	dayPartitions := map[string][]fatsecret.FoodEntryDayData{}
+	for _, day := range data.AggregatedDayData {
+		name := parquetPartitionName("days", day.Date.Year(), int(day.Date.Month()), opts)
+		dayPartitions[name] = append(dayPartitions[name], day)
+	}
+	for name, days := range dayPartitions {
+		if err := dir.WriteFile(name, func(w io.Writer) error {
+			return WriteFoodEntryDaysParquet(w, days)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteFoodEntriesParquet(w io.Writer, entries []fatsecret.FoodEntryData) error {
+	rows := make([]foodEntryParquetRow, len(entries))
+	for i, entry := range entries {
+		nutrient := func(name string, val float64) *float64 {
+			if entry.IsNutrientMissing(name) {
+				return nil
+			}
+			return &val
+		}
+
+		rows[i] = foodEntryParquetRow{
+			Date:                 int32(entry.DateInt),
+			FoodEntryId:          entry.FoodEntryId,
+			FoodId:               entry.FoodId,
+			ServingId:            entry.ServingId,
+			Meal:                 entry.Meal,
+			FoodEntryName:        entry.FoodEntryName,
+			FoodEntryDescription: entry.FoodEntryDescription,
+			NumberOfUnits:        entry.NumberOfUnits,
+			Calories:             nutrient("Calories", entry.Calories),
+			Protein:              nutrient("Protein", entry.Protein),
+			Carbohydrate:         nutrient("Carbohydrate", entry.Carbohydrate),
+			Fat:                  nutrient("Fat", entry.Fat),
+			Fiber:                nutrient("Fiber", entry.Fiber),
+			Sugar:                nutrient("Sugar", entry.Sugar),
+			SaturatedFat:         nutrient("SaturatedFat", entry.SaturatedFat),
+			MonounsaturatedFat:   nutrient("MonounsaturatedFat", entry.MonounsaturatedFat),
+			PolyunsaturatedFat:   nutrient("PolyunsaturatedFat", entry.PolyunsaturatedFat),
+			TransFat:             nutrient("TransFat", entry.TransFat),
+			Cholesterol:          nutrient("Cholesterol", entry.Cholesterol),
+			Sodium:               nutrient("Sodium", entry.Sodium),
+			Potassium:            nutrient("Potassium", entry.Potassium),
+			Calcium:              nutrient("Calcium", entry.Calcium),
+			Iron:                 nutrient("Iron", entry.Iron),
+			VitaminA:             nutrient("VitaminA", entry.VitaminA),
+			VitaminC:             nutrient("VitaminC", entry.VitaminC),
+		}
+		if otherNutrients := formatOtherNutrients(entry); otherNutrients != "" {
+			rows[i].OtherNutrients = &otherNutrients
+		}
+		if entry.Barcode != "" {
+			rows[i].Barcode = &entry.Barcode
+		}
+		if entry.NovaGroup > 0 {
+			novaGroup := int32(entry.NovaGroup)
+			rows[i].NovaGroup = &novaGroup
+		}
+		if entry.Ingredients != "" {
+			rows[i].Ingredients = &entry.Ingredients
+		}
+		if entry.FdcId > 0 {
+			rows[i].FdcId = &entry.FdcId
+		}
+	}
+
+	if err := parquet.Write(w, rows, parquet.Compression(&snappy.Codec{})); err != nil {
+		return fmt.Errorf("Parquet: error when writing food entries: %v", err)
+	}
+	return nil
+}
+
+func WriteFoodEntryDaysParquet(w io.Writer, days []fatsecret.FoodEntryDayData) error {
+	rows := make([]foodEntryDayParquetRow, len(days))
+	for i, day := range days {
+		rows[i] = foodEntryDayParquetRow{
+			Date:         int32(day.DateInt),
+			Calories:     day.Calories,
+			Protein:      day.Protein,
+			Carbohydrate: day.Carbohydrate,
+			Fat:          day.Fat,
+		}
+	}
+
+	if err := parquet.Write(w, rows, parquet.Compression(&snappy.Codec{})); err != nil {
+		return fmt.Errorf("Parquet: error when writing days: %v", err)
+	}
+	return nil
+}
+
+func parquetPartitionName(table string, year int, month int, opts ParquetOptions) string {
+	if !opts.PartitionByMonth {
+		return table + ".parquet"
+	}
+	return fmt.Sprintf("%s/year=%04d/month=%02d/data.parquet", table, year, month)
+}
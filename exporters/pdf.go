@@ -0,0 +1,446 @@
+package exporters
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const pdfMargin = 15.0
+const pdfContentWidth = 180.0
+const pdfRowHeight = 5.5
+
+type PdfOptions struct {
+	FontPath string
+}
+
+type pdfReport struct {
+	pdf       *fpdf.Fpdf
+	family    string
+	translate func(string) string
+}
+
+type pdfColumn struct {
+	Title string
+	Width float64
+	Align string
+}
+
+type pdfMicronutrient struct {
+	Title string
+	Value func(e fatsecret.FoodEntryData) float64
+}
+
+var pdfMicronutrients = []pdfMicronutrient{
+	{"Fiber, g", func(e fatsecret.FoodEntryData) float64 { return e.Fiber }},
+	{"Sugar, g", func(e fatsecret.FoodEntryData) float64 { return e.Sugar }},
+	{"Sat. fat, g", func(e fatsecret.FoodEntryData) float64 { return e.SaturatedFat }},
+	{"Chol., mg", func(e fatsecret.FoodEntryData) float64 { return e.Cholesterol }},
+	{"Sodium, mg", func(e fatsecret.FoodEntryData) float64 { return e.Sodium }},
+	{"Potass., mg", func(e fatsecret.FoodEntryData) float64 { return e.Potassium }},
+	{"Calcium, %", func(e fatsecret.FoodEntryData) float64 { return e.Calcium }},
+	{"Iron, %", func(e fatsecret.FoodEntryData) float64 { return e.Iron }},
+	{"Vit. A, %", func(e fatsecret.FoodEntryData) float64 { return e.VitaminA }},
+	{"Vit. C, %", func(e fatsecret.FoodEntryData) float64 { return e.VitaminC }},
+}
+
+var pdfEntryColumns = []pdfColumn{
+	{"Food", 58, "L"},
+	{"Description", 52, "L"},
+	{"kcal", 16, "R"},
+	{"Protein, g", 18, "R"},
+	{"Carbs, g", 18, "R"},
+	{"Fat, g", 18, "R"},
+}
+
+var macroSeries = []svgSeries{
+	{Name: "Protein", Color: "#4c9f70"},
+	{Name: "Carbohydrate", Color: "#3d7dca"},
+	{Name: "Fat", Color: "#e3b505"},
+}
+
+func WriteDiaryPdfReport(w io.Writer, data *fatsecret.DiaryData, opts PdfOptions) error {
+	report := newDiaryReport(data)
+
+	r := &pdfReport{pdf: fpdf.New("P", "mm", "A4", "")}
+	r.pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	r.pdf.SetAutoPageBreak(true, pdfMargin)
+	r.pdf.AliasNbPages("")
+	r.pdf.SetTitle(fmt.Sprintf("Nutrition report %s – %s", report.FromDate.Format(time.DateOnly), report.ToDate.Format(time.DateOnly)), true)
+	r.pdf.SetCreator("data-migrators487", true)
+
+	if opts.FontPath != "" {
+		font, err := os.ReadFile(opts.FontPath)
+		if err != nil {
+			return fmt.Errorf("PDF: error when reading font: %v", err)
+		}
+		r.family = "report"
+		r.pdf.AddUTF8FontFromBytes(r.family, "", font)
+		r.pdf.AddUTF8FontFromBytes(r.family, "B", font)
+		r.translate = func(val string) string { return val }
+	} else {
+		r.family = "Helvetica"
+		r.translate = r.pdf.UnicodeTranslatorFromDescriptor("")
+	}
+
+	r.pdf.SetFooterFunc(func() {
+		r.pdf.SetY(-pdfMargin + 4)
+		r.setFont("", 8)
+		r.pdf.SetTextColor(128, 128, 128)
+		r.pdf.CellFormat(0, 5, r.translate(fmt.Sprintf("Page %d/{nb}", r.pdf.PageNo())), "", 0, "C", false, 0, "")
+		r.pdf.SetTextColor(0, 0, 0)
+	})
+
+	r.writeCover(report, data)
+	if len(report.Days) > 0 {
+		r.writeCharts(report)
+		r.writeMicronutrients(report)
+		r.writeDays(report)
+	}
+
+	if err := r.pdf.Output(w); err != nil {
+		return fmt.Errorf("PDF: error when writing report: %v", err)
+	}
+	return nil
+}
+
+func (r *pdfReport) writeCover(report *diaryReport, data *fatsecret.DiaryData) {
+	r.pdf.AddPage()
+	r.pdf.Ln(40)
+	r.setFont("B", 24)
+	r.pdf.CellFormat(0, 12, r.translate("Nutrition report"), "", 1, "C", false, 0, "")
+	r.setFont("", 14)
+	r.pdf.CellFormat(0, 10, r.translate(fmt.Sprintf("%s – %s", report.FromDate.Format(time.DateOnly), report.ToDate.Format(time.DateOnly))), "", 1, "C", false, 0, "")
+	r.pdf.Ln(12)
+
+	average := getReportAverage(report)
+	columns := []pdfColumn{{"Metric", 80, "L"}, {"Total", 50, "R"}, {"Daily average", 50, "R"}}
+	r.writeTableHeader(columns)
+	rows := [][]string{
+		{"Days", strconv.Itoa(len(report.Days)), ""},
+		{"Food entries", strconv.Itoa(len(data.DiaryData)), ""},
+		{"Calories, kcal", formatPdfNumber(report.Totals.Calories, 0), formatPdfNumber(average.Calories, 0)},
+		{"Protein, g", formatPdfNumber(report.Totals.Protein, 1), formatPdfNumber(average.Protein, 1)},
+		{"Carbohydrate, g", formatPdfNumber(report.Totals.Carbohydrate, 1), formatPdfNumber(average.Carbohydrate, 1)},
+		{"Fat, g", formatPdfNumber(report.Totals.Fat, 1), formatPdfNumber(average.Fat, 1)},
+	}
+	for _, row := range rows {
+		r.writeTableRow(columns, row, false)
+	}
+
+	r.pdf.Ln(12)
+	r.setFont("B", 12)
+	r.pdf.CellFormat(0, 8, r.translate("Macronutrient energy split"), "", 1, "L", false, 0, "")
+	r.writeMacroSplitBar(r.pdf.GetY()+2, report.Totals)
+}
+
+func (r *pdfReport) writeMacroSplitBar(y float64, totals reportTotals) {
+	shares := getMacroEnergyShares(totals)
+	x := pdfMargin
+	for i, share := range shares {
+		width := pdfContentWidth * share
+		if width <= 0 {
+			continue
+		}
+		r.setFillColor(macroSeries[i].Color)
+		r.pdf.Rect(x, y, width, 10, "F")
+		if width > 20 {
+			r.pdf.SetXY(x, y)
+			r.setFont("B", 9)
+			r.pdf.SetTextColor(255, 255, 255)
+			r.pdf.CellFormat(width, 10, r.translate(fmt.Sprintf("%s %.0f%%", macroSeries[i].Name, share*100)), "", 0, "C", false, 0, "")
+			r.pdf.SetTextColor(0, 0, 0)
+		}
+		x += width
+	}
+	r.pdf.SetXY(pdfMargin, y+12)
+}
+
+func (r *pdfReport) writeCharts(report *diaryReport) {
+	r.pdf.AddPage()
+	r.writeHeading("Charts")
+
+	labels := make([]string, len(report.Days))
+	calories := make([]float64, len(report.Days))
+	macros := make([][]float64, len(macroSeries))
+	shares := make([][]float64, len(macroSeries))
+	for i := range macroSeries {
+		macros[i] = make([]float64, len(report.Days))
+		shares[i] = make([]float64, len(report.Days))
+	}
+	for i, day := range report.Days {
+		labels[i] = day.Date.Format("01-02")
+		calories[i] = day.Totals.Calories
+		macros[0][i], macros[1][i], macros[2][i] = day.Totals.Protein, day.Totals.Carbohydrate, day.Totals.Fat
+		daySplit := getMacroEnergyShares(day.Totals)
+		for j := range macroSeries {
+			shares[j][i] = daySplit[j] * 100
+		}
+	}
+
+	withValues := func(values [][]float64) []svgSeries {
+		res := make([]svgSeries, len(macroSeries))
+		for i, s := range macroSeries {
+			res[i] = svgSeries{Name: s.Name, Color: s.Color, Values: values[i]}
+		}
+		return res
+	}
+
+	y := r.pdf.GetY()
+	r.writeBarChart(y, 70, "Calories, kcal", labels, []svgSeries{{Name: "Calories", Color: "#e07b39", Values: calories}})
+	r.writeBarChart(y+80, 70, "Macronutrients, g", labels, withValues(macros))
+	r.writeBarChart(y+160, 70, "Macronutrient energy split, %", labels, withValues(shares))
+}
+
+func (r *pdfReport) writeBarChart(y float64, height float64, title string, labels []string, series []svgSeries) {
+	const axisWidth = 14.0
+	const titleHeight = 8.0
+	const labelsHeight = 6.0
+
+	plotX := pdfMargin + axisWidth
+	plotWidth := pdfContentWidth - axisWidth
+	plotTop := y + titleHeight
+	plotHeight := height - titleHeight - labelsHeight
+	plotBottom := plotTop + plotHeight
+
+	r.pdf.SetXY(pdfMargin, y)
+	r.setFont("B", 10)
+	r.pdf.CellFormat(pdfContentWidth/2, 6, r.translate(title), "", 0, "L", false, 0, "")
+
+	if len(series) > 1 {
+		legendX := pdfMargin + pdfContentWidth
+		r.setFont("", 8)
+		for i := len(series) - 1; i >= 0; i-- {
+			legendX -= r.pdf.GetStringWidth(r.translate(series[i].Name)) + 7
+			r.setFillColor(series[i].Color)
+			r.pdf.Rect(legendX, y+1.5, 3, 3, "F")
+			r.pdf.SetXY(legendX+3.5, y)
+			r.pdf.CellFormat(0, 6, r.translate(series[i].Name), "", 0, "L", false, 0, "")
+		}
+	}
+
+	var maxValue float64
+	for i := range labels {
+		var sum float64
+		for _, s := range series {
+			sum += s.Values[i]
+		}
+		if sum > maxValue {
+			maxValue = sum
+		}
+	}
+	scaleMax, scaleStep := getSvgScale(maxValue)
+
+	r.setFont("", 7)
+	r.pdf.SetDrawColor(220, 220, 220)
+	r.pdf.SetLineWidth(0.1)
+	for i := 0; float64(i)*scaleStep <= scaleMax+scaleStep/2; i++ {
+		val := float64(i) * scaleStep
+		lineY := plotBottom - val/scaleMax*plotHeight
+		r.pdf.Line(plotX, lineY, plotX+plotWidth, lineY)
+		r.pdf.SetXY(pdfMargin, lineY-2)
+		r.pdf.CellFormat(axisWidth-1, 4, formatSvgNumber(val), "", 0, "R", false, 0, "")
+	}
+
+	slotWidth := plotWidth / float64(len(labels))
+	barWidth := slotWidth * 0.7
+	labelStep := (len(labels) + svgChartMaxLabels - 1) / svgChartMaxLabels
+	for i, label := range labels {
+		x := plotX + slotWidth*float64(i) + (slotWidth-barWidth)/2
+		barY := plotBottom
+		for _, s := range series {
+			barHeight := s.Values[i] / scaleMax * plotHeight
+			barY -= barHeight
+			if barHeight > 0 {
+				r.setFillColor(s.Color)
+				r.pdf.Rect(x, barY, barWidth, barHeight, "F")
+			}
+		}
+		if i%labelStep == 0 {
+			r.pdf.SetXY(x-5, plotBottom+1)
+			r.pdf.CellFormat(barWidth+10, 4, label, "", 0, "C", false, 0, "")
+		}
+	}
+
+	r.pdf.SetDrawColor(150, 150, 150)
+	r.pdf.Line(plotX, plotBottom, plotX+plotWidth, plotBottom)
+	r.pdf.SetDrawColor(0, 0, 0)
+	r.pdf.SetLineWidth(0.2)
+}
+
+func (r *pdfReport) writeMicronutrients(report *diaryReport) {
+	r.pdf.AddPage()
+	r.writeHeading("Micronutrients")
+
+	columns := []pdfColumn{{"Date", 20, "L"}}
+	for _, nutrient := range pdfMicronutrients {
+		columns = append(columns, pdfColumn{nutrient.Title, (pdfContentWidth - 20) / float64(len(pdfMicronutrients)), "R"})
+	}
+
+	totals := make([]float64, len(pdfMicronutrients))
+	r.setFont("", 7)
+	r.writeTableHeader(columns)
+	for _, day := range report.Days {
+		values := make([]float64, len(pdfMicronutrients))
+		for _, meal := range day.Meals {
+			for _, entry := range meal.Entries {
+				for i, nutrient := range pdfMicronutrients {
+					values[i] += nutrient.Value(entry)
+				}
+			}
+		}
+
+		row := []string{day.Date.Format(time.DateOnly)}
+		for i, val := range values {
+			totals[i] += val
+			row = append(row, formatPdfNumber(val, 1))
+		}
+		if r.ensureSpace(pdfRowHeight) {
+			r.writeTableHeader(columns)
+		}
+		r.writeTableRow(columns, row, false)
+	}
+
+	row := []string{"Average"}
+	for _, val := range totals {
+		row = append(row, formatPdfNumber(val/float64(len(report.Days)), 1))
+	}
+	r.ensureSpace(pdfRowHeight)
+	r.writeTableRow(columns, row, true)
+
+	r.pdf.Ln(4)
+	r.setFont("", 8)
+	r.pdf.MultiCell(0, 4, r.translate("Calcium, iron and vitamins A and C are percentages of the recommended daily value."), "", "L", false)
+}
+
+func (r *pdfReport) writeDays(report *diaryReport) {
+	r.pdf.AddPage()
+	r.writeHeading("Diary")
+
+	for _, day := range report.Days {
+		r.ensureSpace(pdfRowHeight * 5)
+		r.setFont("B", 12)
+		r.pdf.CellFormat(0, 8, r.translate(day.Date.Format("2006-01-02, Monday")), "", 1, "L", false, 0, "")
+		r.setFont("", 9)
+		r.pdf.CellFormat(0, 5, r.translate("Total: "+formatReportTotals(day.Totals)), "", 1, "L", false, 0, "")
+
+		for _, meal := range day.Meals {
+			r.ensureSpace(pdfRowHeight * 3)
+			r.setFont("B", 10)
+			r.pdf.CellFormat(0, 7, r.translate(meal.Name), "", 1, "L", false, 0, "")
+			r.setFont("", 8)
+			r.writeTableHeader(pdfEntryColumns)
+			for _, entry := range meal.Entries {
+				if r.ensureSpace(pdfRowHeight) {
+					r.writeTableHeader(pdfEntryColumns)
+				}
+				r.writeTableRow(pdfEntryColumns, []string{
+					entry.FoodEntryName,
+					entry.FoodEntryDescription,
+					formatPdfNumber(entry.Calories, 0),
+					formatPdfNumber(entry.Protein, 1),
+					formatPdfNumber(entry.Carbohydrate, 1),
+					formatPdfNumber(entry.Fat, 1),
+				}, false)
+			}
+			r.ensureSpace(pdfRowHeight)
+			r.writeTableRow(pdfEntryColumns, []string{
+				"Total",
+				"",
+				formatPdfNumber(meal.Totals.Calories, 0),
+				formatPdfNumber(meal.Totals.Protein, 1),
+				formatPdfNumber(meal.Totals.Carbohydrate, 1),
+				formatPdfNumber(meal.Totals.Fat, 1),
+			}, true)
+		}
+		r.pdf.Ln(4)
+	}
+}
+
+func (r *pdfReport) writeHeading(title string) {
+	r.setFont("B", 16)
+	r.pdf.CellFormat(0, 10, r.translate(title), "", 1, "L", false, 0, "")
+	r.pdf.Ln(2)
+}
+
+func (r *pdfReport) writeTableHeader(columns []pdfColumn) {
+	fontSize, _ := r.pdf.GetFontSize()
+	r.setFont("B", fontSize)
+	r.setFillColor("#ddebf7")
+	for _, col := range columns {
+		r.pdf.CellFormat(col.Width, pdfRowHeight, r.fitText(col.Title, col.Width), "1", 0, col.Align, true, 0, "")
+	}
+	r.pdf.Ln(-1)
+	r.setFont("", fontSize)
+}
+
+func (r *pdfReport) writeTableRow(columns []pdfColumn, values []string, isTotal bool) {
+	fontSize, _ := r.pdf.GetFontSize()
+	if isTotal {
+		r.setFont("B", fontSize)
+		r.setFillColor("#f2f2f2")
+	}
+	for i, col := range columns {
+		r.pdf.CellFormat(col.Width, pdfRowHeight, r.fitText(values[i], col.Width), "1", 0, col.Align, isTotal, 0, "")
+	}
+	r.pdf.Ln(-1)
+	if isTotal {
+		r.setFont("", fontSize)
+	}
+}
+
+func (r *pdfReport) ensureSpace(height float64) bool {
+	_, pageHeight := r.pdf.GetPageSize()
+	if r.pdf.GetY()+height <= pageHeight-pdfMargin {
+		return false
+	}
+	r.pdf.AddPage()
+	return true
+}
+
+func (r *pdfReport) fitText(val string, width float64) string {
+	maxWidth := width - 2*r.pdf.GetCellMargin()
+	res := r.translate(val)
+	if r.pdf.GetStringWidth(res) <= maxWidth {
+		return res
+	}
+
+	runes := []rune(val)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if res = r.translate(string(runes) + "…"); r.pdf.GetStringWidth(res) <= maxWidth {
+			break
+		}
+	}
+	return res
+}
+
+func (r *pdfReport) setFont(style string, size float64) {
+	r.pdf.SetFont(r.family, style, size)
+}
+
+func (r *pdfReport) setFillColor(hexColor string) {
+	red, _ := strconv.ParseUint(hexColor[1:3], 16, 8)
+	green, _ := strconv.ParseUint(hexColor[3:5], 16, 8)
+	blue, _ := strconv.ParseUint(hexColor[5:7], 16, 8)
+	r.pdf.SetFillColor(int(red), int(green), int(blue))
+}
+
+func getMacroEnergyShares(totals reportTotals) []float64 {
+	energy := []float64{totals.Protein * 4, totals.Carbohydrate * 4, totals.Fat * 9}
+	sum := energy[0] + energy[1] + energy[2]
+	if sum == 0 {
+		return []float64{0, 0, 0}
+	}
+	return []float64{energy[0] / sum, energy[1] / sum, energy[2] / sum}
+}
+
+func formatPdfNumber(val float64, precision int) string {
+	return strconv.FormatFloat(val, 'f', precision, 64)
+}
@@ -0,0 +1,286 @@
+package exporters
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+var reportMealsOrder = map[string]int{"breakfast": 0, "lunch": 1, "dinner": 2}
+
+type diaryReport struct {
+	FromDate time.Time
+	ToDate   time.Time
+	Totals   reportTotals
+	Days     []*reportDay
+	Charts   []reportChart
+}
+
+type reportDay struct {
+	Date   time.Time
+	Totals reportTotals
+	Meals  []*reportMeal
+}
+
+type reportMeal struct {
+	Name    string
+	Totals  reportTotals
+	Entries []fatsecret.FoodEntryData
+}
+
+type reportTotals struct {
+	Calories     float64
+	Protein      float64
+	Carbohydrate float64
+	Fat          float64
+}
+
+type reportChart struct {
+	Title string
+	Svg   string
+}
+
+func (t *reportTotals) add(calories float64, protein float64, carbohydrate float64, fat float64) {
+	t.Calories += calories
+	t.Protein += protein
+	t.Carbohydrate += carbohydrate
+	t.Fat += fat
+}
+
+func newDiaryReport(data *fatsecret.DiaryData) *diaryReport {
+	res := &diaryReport{FromDate: data.FromDate, ToDate: data.ToDate}
+
+	days := map[int64]*reportDay{}
+	getDay := func(dateInt int64, date time.Time) *reportDay {
+		day, ok := days[dateInt]
+		if !ok {
+			day = &reportDay{Date: date}
+			days[dateInt] = day
+			res.Days = append(res.Days, day)
+		}
+		return day
+	}
+
+	for _, dayData := range data.AggregatedDayData {
+		day := getDay(dayData.DateInt, dayData.Date)
+		day.Totals.add(dayData.Calories, dayData.Protein, dayData.Carbohydrate, dayData.Fat)
+	}
+
+	withEntries := map[int64]bool{}
+	for _, entry := range data.DiaryData {
+		day := getDay(entry.DateInt, entry.Date)
+		if !withEntries[entry.DateInt] {
+			withEntries[entry.DateInt] = true
+			day.Totals = reportTotals{}
+		}
+		day.Totals.add(entry.Calories, entry.Protein, entry.Carbohydrate, entry.Fat)
+
+		var meal *reportMeal
+		for _, item := range day.Meals {
+			if item.Name == entry.Meal {
+				meal = item
+				break
+			}
+		}
+		if meal == nil {
+			meal = &reportMeal{Name: entry.Meal}
+			day.Meals = append(day.Meals, meal)
+		}
+		meal.Totals.add(entry.Calories, entry.Protein, entry.Carbohydrate, entry.Fat)
+		meal.Entries = append(meal.Entries, entry)
+	}
+
+	sort.Slice(res.Days, func(i, j int) bool {
+		return res.Days[i].Date.Before(res.Days[j].Date)
+	})
+	for _, day := range res.Days {
+		sort.SliceStable(day.Meals, func(i, j int) bool {
+			return getReportMealOrder(day.Meals[i].Name) < getReportMealOrder(day.Meals[j].Name)
+		})
+		res.Totals.add(day.Totals.Calories, day.Totals.Protein, day.Totals.Carbohydrate, day.Totals.Fat)
+	}
+	if len(res.Days) > 0 {
+		if res.FromDate.IsZero() {
+			res.FromDate = res.Days[0].Date
+		}
+		if res.ToDate.IsZero() {
+			res.ToDate = res.Days[len(res.Days)-1].Date
+		}
+	}
+
+	res.Charts = getReportCharts(res.Days)
+	return res
+}
+
+func getReportMealOrder(meal string) int {
+	if order, ok := reportMealsOrder[strings.ToLower(meal)]; ok {
+		return order
+	}
+	return len(reportMealsOrder)
+}
+
+func getReportCharts(days []*reportDay) []reportChart {
+	if len(days) == 0 {
+		return nil
+	}
+
+	labels := make([]string, len(days))
+	calories := make([]float64, len(days))
+	protein := make([]float64, len(days))
+	carbohydrate := make([]float64, len(days))
+	fat := make([]float64, len(days))
+	for i, day := range days {
+		labels[i] = day.Date.Format("01-02")
+		calories[i] = day.Totals.Calories
+		protein[i] = day.Totals.Protein
+		carbohydrate[i] = day.Totals.Carbohydrate
+		fat[i] = day.Totals.Fat
+	}
+
+	return []reportChart{
+		{
+			Title: "Calories",
+			Svg: renderSvgBarChart("Calories, kcal", labels, []svgSeries{
+				{Name: "Calories", Color: "#e07b39", Values: calories},
+			}),
+		},
+		{
+			Title: "Macronutrients",
+			Svg: renderSvgBarChart("Macronutrients, g", labels, []svgSeries{
+				{Name: "Protein", Color: "#4c9f70", Values: protein},
+				{Name: "Carbohydrate", Color: "#3d7dca", Values: carbohydrate},
+				{Name: "Fat", Color: "#e3b505", Values: fat},
+			}),
+		},
+	}
+}
+
+func WriteDiaryMarkdownReport(w io.Writer, data *fatsecret.DiaryData) error {
+	report := newDiaryReport(data)
+	bw := bufio.NewWriter(w)
+
+	_, _ = fmt.Fprintf(bw, "# Diary %s – %s\n\n", report.FromDate.Format(time.DateOnly), report.ToDate.Format(time.DateOnly))
+	_, _ = fmt.Fprintf(bw, "%d days, %s in total, %s per day on average.\n\n",
+		len(report.Days), formatReportTotals(report.Totals), formatReportTotals(getReportAverage(report)))
+
+	for _, chart := range report.Charts {
+		_, _ = fmt.Fprintf(bw, "![%s](data:image/svg+xml;base64,%s)\n\n", chart.Title, base64.StdEncoding.EncodeToString([]byte(chart.Svg)))
+	}
+
+	for _, day := range report.Days {
+		_, _ = fmt.Fprintf(bw, "## %s\n\n", day.Date.Format("2006-01-02, Monday"))
+		_, _ = fmt.Fprintf(bw, "Total: %s\n\n", formatReportTotals(day.Totals))
+
+		for _, meal := range day.Meals {
+			_, _ = fmt.Fprintf(bw, "### %s\n\n", escapeMarkdown(meal.Name))
+			_, _ = bw.WriteString("| Food | Description | kcal | Protein, g | Carbs, g | Fat, g |\n")
+			_, _ = bw.WriteString("|---|---|--:|--:|--:|--:|\n")
+			for _, entry := range meal.Entries {
+				_, _ = fmt.Fprintf(bw, "| %s | %s | %.0f | %.1f | %.1f | %.1f |\n",
+					escapeMarkdown(entry.FoodEntryName), escapeMarkdown(entry.FoodEntryDescription),
+					entry.Calories, entry.Protein, entry.Carbohydrate, entry.Fat)
+			}
+			_, _ = fmt.Fprintf(bw, "| **Total** | | **%.0f** | **%.1f** | **%.1f** | **%.1f** |\n\n",
+				meal.Totals.Calories, meal.Totals.Protein, meal.Totals.Carbohydrate, meal.Totals.Fat)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("Report: error when writing Markdown: %v", err)
+	}
+	return nil
+}
+
+func WriteDiaryHtmlReport(w io.Writer, data *fatsecret.DiaryData) error {
+	report := newDiaryReport(data)
+	if err := htmlReportTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("Report: error when writing HTML: %v", err)
+	}
+	return nil
+}
+
+func getReportAverage(report *diaryReport) reportTotals {
+	if len(report.Days) == 0 {
+		return reportTotals{}
+	}
+	count := float64(len(report.Days))
+	return reportTotals{
+		Calories:     report.Totals.Calories / count,
+		Protein:      report.Totals.Protein / count,
+		Carbohydrate: report.Totals.Carbohydrate / count,
+		Fat:          report.Totals.Fat / count,
+	}
+}
+
+func formatReportTotals(totals reportTotals) string {
+	return fmt.Sprintf("%.0f kcal, protein %.1f g, carbs %.1f g, fat %.1f g",
+		totals.Calories, totals.Protein, totals.Carbohydrate, totals.Fat)
+}
+
+func escapeMarkdown(val string) string {
+	val = strings.ReplaceAll(val, "\\", "\\\\")
+	val = strings.ReplaceAll(val, "|", "\\|")
+	val = strings.ReplaceAll(val, "<", "&lt;")
+	return strings.Join(strings.Fields(val), " ")
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(date time.Time) string {
+		return date.Format(time.DateOnly)
+	},
+	"dayTitle": func(date time.Time) string {
+		return date.Format("2006-01-02, Monday")
+	},
+	"num": func(precision int, val float64) string {
+		return fmt.Sprintf("%.*f", precision, val)
+	},
+	"totals":  formatReportTotals,
+	"average": getReportAverage,
+	"svg": func(val string) template.HTML {
+		return template.HTML(val)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Diary {{date .FromDate}} – {{date .ToDate}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: .2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #ddd; padding: .3em .6em; text-align: left; }
+td.num, th.num { text-align: right; }
+tr.total td { font-weight: bold; background: #f6f6f6; }
+.description { color: #666; }
+svg { max-width: 100%; height: auto; }
+</style>
+</head>
+<body>
+<h1>Diary {{date .FromDate}} – {{date .ToDate}}</h1>
+<p>{{len .Days}} days, {{totals .Totals}} in total, {{totals (average .)}} per day on average.</p>
+{{range .Charts}}<figure>{{svg .Svg}}</figure>
+{{end}}
+{{- range .Days}}
+<h2>{{dayTitle .Date}}</h2>
+<p>Total: {{totals .Totals}}</p>
+{{- range .Meals}}
+<h3>{{.Name}}</h3>
+<table>
+<tr><th>Food</th><th>Description</th><th class="num">kcal</th><th class="num">Protein, g</th><th class="num">Carbs, g</th><th class="num">Fat, g</th></tr>
+{{- range .Entries}}
+<tr><td>{{.FoodEntryName}}</td><td class="description">{{.FoodEntryDescription}}</td><td class="num">{{num 0 .Calories}}</td><td class="num">{{num 1 .Protein}}</td><td class="num">{{num 1 .Carbohydrate}}</td><td class="num">{{num 1 .Fat}}</td></tr>
+{{- end}}
+<tr class="total"><td>Total</td><td></td><td class="num">{{num 0 .Totals.Calories}}</td><td class="num">{{num 1 .Totals.Protein}}</td><td class="num">{{num 1 .Totals.Carbohydrate}}</td><td class="num">{{num 1 .Totals.Fat}}</td></tr>
+</table>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,243 @@
+package exporters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const shardManifestFile = "manifest.json"
+
+type ShardManifest struct {
+	Format   string      `json:"format"`
+	FromDate string      `json:"from_date"`
+	ToDate   string      `json:"to_date"`
+	Shards   []ShardInfo `json:"shards"`
+}
+
+type ShardInfo struct {
+	File        string `json:"file"`
+	Month       string `json:"month"`
+	FromDate    string `json:"from_date"`
+	ToDate      string `json:"to_date"`
+	Days        int    `json:"days"`
+	FoodEntries int    `json:"food_entries"`
+	Weights     int    `json:"weights"`
+	Exercises   int    `json:"exercises"`
+	Size        int64  `json:"size"`
+	Sha256      string `json:"sha256"`
+}
+
+func WriteDiaryShards(dir OutputDir, data *fatsecret.DiaryData, format string, ext string, write func(w io.Writer, shard *fatsecret.DiaryData) error) error {
+	manifest, err := readShardManifest(dir.Path)
+	if err != nil {
+		return err
+	}
+	if manifest.Format != format {
+		manifest = &ShardManifest{Format: format}
+	}
+
+	shards := map[string]ShardInfo{}
+	for _, shard := range manifest.Shards {
+		shards[shard.Month] = shard
+	}
+
+	monthShards := SplitDiaryByMonth(data)
+	// Shards can't be merged in every format, so a shard is only replaced by one covering its whole range
+	for _, shard := range monthShards {
+		prevShard, ok := shards[shard.FromDate.Format("2006-01")]
+		if !ok {
+			continue
+		}
+		fromDate, toDate := shard.FromDate.Format(time.DateOnly), shard.ToDate.Format(time.DateOnly)
+		if fromDate > prevShard.FromDate || toDate < prevShard.ToDate {
+			return fmt.Errorf(
+				"dates %s to %s would overwrite shard %s of %s to %s with partial data, export the whole month instead",
+				fromDate, toDate, prevShard.File, prevShard.FromDate, prevShard.ToDate,
+			)
+		}
+	}
+
+	for _, shard := range monthShards {
+		month := shard.FromDate.Format("2006-01")
+		fileName := month + ext + dir.Compression.Ext()
+		if err := dir.WriteFile(month+ext, func(w io.Writer) error {
+			return write(w, shard)
+		}); err != nil {
+			return err
+		}
+
+		if prevShard, ok := shards[month]; ok && prevShard.File != fileName {
+			if err := os.Remove(path.Join(dir.Path, prevShard.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("WARN: error when removing previous shard: %v", err)
+			}
+		}
+
+		size, sha, err := getFileDigest(path.Join(dir.Path, fileName))
+		if err != nil {
+			return err
+		}
+		shards[month] = ShardInfo{
+			File:        fileName,
+			Month:       month,
+			FromDate:    shard.FromDate.Format(time.DateOnly),
+			ToDate:      shard.ToDate.Format(time.DateOnly),
+			Days:        len(shard.AggregatedDayData),
+			FoodEntries: len(shard.DiaryData),
+			Weights:     len(shard.Weights),
+			Exercises:   len(shard.Exercises),
+			Size:        size,
+			Sha256:      sha,
+		}
+	}
+
+	manifest.Shards = make([]ShardInfo, 0, len(shards))
+	for _, shard := range shards {
+		manifest.Shards = append(manifest.Shards, shard)
+	}
+	sort.Slice(manifest.Shards, func(i, j int) bool {
+		return manifest.Shards[i].Month < manifest.Shards[j].Month
+	})
+	if len(manifest.Shards) > 0 {
+		manifest.FromDate = manifest.Shards[0].FromDate
+		manifest.ToDate = manifest.Shards[len(manifest.Shards)-1].ToDate
+	}
+
+	return WriteFile(path.Join(dir.Path, shardManifestFile), NoCompression, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifest)
+	})
+}
+
+func SplitDiaryByMonth(data *fatsecret.DiaryData) []*fatsecret.DiaryData {
+	fromDate, toDate := getDiaryShardsRange(data)
+	if fromDate.IsZero() || toDate.Before(fromDate) {
+		return nil
+	}
+
+	var res []*fatsecret.DiaryData
+	shards := map[string]*fatsecret.DiaryData{}
+	monthStart := time.Date(fromDate.Year(), fromDate.Month(), 1, 0, 0, 0, 0, fromDate.Location())
+	for !monthStart.After(toDate) {
+		shard := &fatsecret.DiaryData{
+			FromDate: monthStart,
+			ToDate:   monthStart.AddDate(0, 1, -1),
+		}
+		if shard.FromDate.Before(fromDate) {
+			shard.FromDate = fromDate
+		}
+		if shard.ToDate.After(toDate) {
+			shard.ToDate = toDate
+		}
+
+		res = append(res, shard)
+		shards[monthStart.Format("2006-01")] = shard
+		monthStart = monthStart.AddDate(0, 1, 0)
+	}
+
+	getShard := func(date time.Time) *fatsecret.DiaryData {
+		return shards[date.Format("2006-01")]
+	}
+	for _, day := range data.AggregatedDayData {
+		if shard := getShard(day.Date); shard != nil {
+			shard.AggregatedDayData = append(shard.AggregatedDayData, day)
+		}
+	}
+	for _, entry := range data.DiaryData {
+		if shard := getShard(entry.Date); shard != nil {
+			shard.DiaryData = append(shard.DiaryData, entry)
+		}
+	}
+	for _, weight := range data.Weights {
+		if shard := getShard(weight.Date); shard != nil {
+			shard.Weights = append(shard.Weights, weight)
+		}
+	}
+	for _, exercise := range data.Exercises {
+		if shard := getShard(exercise.Date); shard != nil {
+			shard.Exercises = append(shard.Exercises, exercise)
+		}
+	}
+
+	return res
+}
+
+// Imported diaries take their range from food entries, so weights and exercises can lie outside of it
+func getDiaryShardsRange(data *fatsecret.DiaryData) (time.Time, time.Time) {
+	fromDate, toDate := data.FromDate, data.ToDate
+	var dates []time.Time
+	for _, day := range data.AggregatedDayData {
+		dates = append(dates, day.Date)
+	}
+	for _, entry := range data.DiaryData {
+		dates = append(dates, entry.Date)
+	}
+	for _, weight := range data.Weights {
+		dates = append(dates, weight.Date)
+	}
+	for _, exercise := range data.Exercises {
+		dates = append(dates, exercise.Date)
+	}
+
+	loc := time.UTC
+	if !fromDate.IsZero() {
+		loc = fromDate.Location()
+	}
+	for _, date := range dates {
+		day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+		if fromDate.IsZero() || day.Before(fromDate) {
+			fromDate = day
+		}
+		if toDate.IsZero() || day.After(toDate) {
+			toDate = day
+		}
+	}
+	return fromDate, toDate
+}
+
+func readShardManifest(dirPath string) (*ShardManifest, error) {
+	manifestPath := path.Join(dirPath, shardManifestFile)
+	data, err := os.ReadFile(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &ShardManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error when reading shard manifest: %v", err)
+	}
+
+	res := &ShardManifest{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, fmt.Errorf("error when parsing shard manifest %s: %v", manifestPath, err)
+	}
+	return res, nil
+}
+
+func getFileDigest(filePath string) (int64, string, error) {
+	fp, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", fmt.Errorf("error when opening file: %v", err)
+	}
+	defer func() {
+		if err := fp.Close(); err != nil {
+			log.Printf("WARN: error when closing file: %v", err)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, fp)
+	if err != nil {
+		return 0, "", fmt.Errorf("error when reading file %s: %v", filePath, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,58 @@
+package exporters
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+func getShardsTestDiary(fromDay int, toDay int) *fatsecret.DiaryData {
+	data := &fatsecret.DiaryData{
+		FromDate: time.Date(2024, 3, fromDay, 0, 0, 0, 0, time.UTC),
+		ToDate:   time.Date(2024, 4, toDay, 0, 0, 0, 0, time.UTC),
+	}
+	for date := data.FromDate; !date.After(data.ToDate); date = date.AddDate(0, 0, 1) {
+		data.AggregatedDayData = append(data.AggregatedDayData, fatsecret.FoodEntryDayData{Date: date, Calories: 2000})
+	}
+	return data
+}
+
+func writeTestShards(dir OutputDir, data *fatsecret.DiaryData) error {
+	return WriteDiaryShards(dir, data, "json", ".json", func(w io.Writer, shard *fatsecret.DiaryData) error {
+		_, err := io.WriteString(w, shard.FromDate.Format(time.DateOnly))
+		return err
+	})
+}
+
+func TestWriteDiaryShardsRefusesPartialMonth(t *testing.T) {
+	dir := OutputDir{Path: t.TempDir()}
+	if err := writeTestShards(dir, getShardsTestDiary(1, 30)); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeTestShards(dir, getShardsTestDiary(15, 30))
+	if err == nil || !strings.Contains(err.Error(), "would overwrite shard 2024-03.json") {
+		t.Fatalf("expected partial month error, got %v", err)
+	}
+	for month, expected := range map[string]string{"2024-03": "2024-03-01", "2024-04": "2024-04-01"} {
+		if content, err := os.ReadFile(path.Join(dir.Path, month+".json")); err != nil || string(content) != expected {
+			t.Errorf("shard %s is changed: %q, %v", month, content, err)
+		}
+	}
+
+	if err := writeTestShards(dir, getShardsTestDiary(1, 30)); err != nil {
+		t.Errorf("whole months are not rewritten: %v", err)
+	}
+	manifest, err := readShardManifest(dir.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Shards) != 2 || manifest.FromDate != "2024-03-01" || manifest.ToDate != "2024-04-30" {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+}
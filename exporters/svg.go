@@ -0,0 +1,109 @@
+package exporters
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const svgChartWidth = 800
+const svgChartHeight = 280
+const svgChartMarginLeft = 56
+const svgChartMarginRight = 16
+const svgChartMarginTop = 40
+const svgChartMarginBottom = 36
+const svgChartMaxLabels = 16
+
+type svgSeries struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+func renderSvgBarChart(title string, labels []string, series []svgSeries) string {
+	plotWidth := float64(svgChartWidth - svgChartMarginLeft - svgChartMarginRight)
+	plotHeight := float64(svgChartHeight - svgChartMarginTop - svgChartMarginBottom)
+	plotBottom := float64(svgChartHeight - svgChartMarginBottom)
+
+	var maxValue float64
+	for i := range labels {
+		var sum float64
+		for _, s := range series {
+			sum += s.Values[i]
+		}
+		maxValue = math.Max(maxValue, sum)
+	}
+	scaleMax, scaleStep := getSvgScale(maxValue)
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`,
+		svgChartWidth, svgChartHeight, svgChartWidth, svgChartHeight)
+	_, _ = fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="#fff"/>`)
+	_, _ = fmt.Fprintf(&sb, `<text x="%d" y="20" font-size="14" font-weight="bold">%s</text>`, svgChartMarginLeft, html.EscapeString(title))
+
+	legendX := float64(svgChartWidth - svgChartMarginRight)
+	for i := len(series) - 1; i >= 0 && len(series) > 1; i-- {
+		legendX -= float64(len(series[i].Name))*7 + 24
+		_, _ = fmt.Fprintf(&sb, `<rect x="%s" y="11" width="10" height="10" fill="%s"/><text x="%s" y="20">%s</text>`,
+			formatSvgNumber(legendX), series[i].Color, formatSvgNumber(legendX+14), html.EscapeString(series[i].Name))
+	}
+
+	for i := 0; float64(i)*scaleStep <= scaleMax+scaleStep/2; i++ {
+		val := float64(i) * scaleStep
+		y := plotBottom - val/scaleMax*plotHeight
+		_, _ = fmt.Fprintf(&sb, `<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="#e5e5e5"/>`,
+			svgChartMarginLeft, formatSvgNumber(y), svgChartWidth-svgChartMarginRight, formatSvgNumber(y))
+		_, _ = fmt.Fprintf(&sb, `<text x="%d" y="%s" text-anchor="end">%s</text>`,
+			svgChartMarginLeft-6, formatSvgNumber(y+4), formatSvgNumber(val))
+	}
+
+	if len(labels) > 0 {
+		slotWidth := plotWidth / float64(len(labels))
+		barWidth := slotWidth * 0.7
+		labelStep := int(math.Ceil(float64(len(labels)) / svgChartMaxLabels))
+
+		for i, label := range labels {
+			x := float64(svgChartMarginLeft) + slotWidth*float64(i) + (slotWidth-barWidth)/2
+			y := plotBottom
+			for _, s := range series {
+				height := s.Values[i] / scaleMax * plotHeight
+				y -= height
+				_, _ = fmt.Fprintf(&sb, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"><title>%s %s: %s</title></rect>`,
+					formatSvgNumber(x), formatSvgNumber(y), formatSvgNumber(barWidth), formatSvgNumber(height), s.Color,
+					html.EscapeString(label), html.EscapeString(s.Name), formatSvgNumber(s.Values[i]))
+			}
+			if i%labelStep == 0 {
+				_, _ = fmt.Fprintf(&sb, `<text x="%s" y="%s" text-anchor="middle">%s</text>`,
+					formatSvgNumber(x+barWidth/2), formatSvgNumber(plotBottom+16), html.EscapeString(label))
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintf(&sb, `<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="#999"/>`,
+		svgChartMarginLeft, formatSvgNumber(plotBottom), svgChartWidth-svgChartMarginRight, formatSvgNumber(plotBottom))
+	sb.WriteString("</svg>")
+	return sb.String()
+}
+
+func getSvgScale(maxValue float64) (float64, float64) {
+	if maxValue <= 0 {
+		return 1, 0.25
+	}
+
+	rawStep := maxValue / 4
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := magnitude
+	for _, mult := range []float64{1, 2, 2.5, 5, 10} {
+		step = mult * magnitude
+		if step >= rawStep {
+			break
+		}
+	}
+	return math.Ceil(maxValue/step) * step, step
+}
+
+func formatSvgNumber(val float64) string {
+	return strconv.FormatFloat(math.Round(val*100)/100, 'f', -1, 64)
+}
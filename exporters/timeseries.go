@@ -0,0 +1,209 @@
+package exporters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+type TimeSeriesOptions struct {
+	DayMeasurement  string
+	MealMeasurement string
+	MealTag         string
+	Tags            map[string]string
+}
+
+func DefaultTimeSeriesOptions() TimeSeriesOptions {
+	return TimeSeriesOptions{
+		DayMeasurement:  "nutrition_daily",
+		MealMeasurement: "nutrition_meal",
+		MealTag:         "meal",
+		Tags:            map[string]string{},
+	}
+}
+
+type timeSeriesPoint struct {
+	Date         time.Time
+	Meal         string
+	Entries      int64
+	Calories     float64
+	Protein      float64
+	Carbohydrate float64
+	Fat          float64
+}
+
+type timeSeriesField struct {
+	Name   string
+	Metric string
+	Value  func(p timeSeriesPoint) float64
+}
+
+var timeSeriesFields = []timeSeriesField{
+	{"calories", "calories", func(p timeSeriesPoint) float64 { return p.Calories }},
+	{"protein", "protein_grams", func(p timeSeriesPoint) float64 { return p.Protein }},
+	{"carbohydrate", "carbohydrate_grams", func(p timeSeriesPoint) float64 { return p.Carbohydrate }},
+	{"fat", "fat_grams", func(p timeSeriesPoint) float64 { return p.Fat }},
+}
+
+func WriteDiaryInfluxLineProtocol(w io.Writer, data *fatsecret.DiaryData, opts TimeSeriesOptions) error {
+	bw := bufio.NewWriter(w)
+
+	for _, point := range getDayTimeSeriesPoints(data) {
+		writeInfluxLine(bw, opts.DayMeasurement, opts.Tags, "", "", point)
+	}
+	for _, point := range getMealTimeSeriesPoints(data) {
+		writeInfluxLine(bw, opts.MealMeasurement, opts.Tags, opts.MealTag, point.Meal, point)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("InfluxDB: error when writing line protocol: %v", err)
+	}
+	return nil
+}
+
+func WriteDiaryOpenMetrics(w io.Writer, data *fatsecret.DiaryData, opts TimeSeriesOptions) error {
+	bw := bufio.NewWriter(w)
+
+	dayPoints := getDayTimeSeriesPoints(data)
+	mealPoints := getMealTimeSeriesPoints(data)
+	for _, field := range timeSeriesFields {
+		writeOpenMetricsFamily(bw, opts.DayMeasurement+"_"+field.Metric, opts.Tags, "", field, dayPoints)
+		writeOpenMetricsFamily(bw, opts.MealMeasurement+"_"+field.Metric, opts.Tags, opts.MealTag, field, mealPoints)
+	}
+	_, _ = bw.WriteString("# EOF\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("OpenMetrics: error when writing metrics: %v", err)
+	}
+	return nil
+}
+
+func getDayTimeSeriesPoints(data *fatsecret.DiaryData) []timeSeriesPoint {
+	entriesCount := map[int64]int64{}
+	for _, entry := range data.DiaryData {
+		entriesCount[entry.DateInt]++
+	}
+
+	res := make([]timeSeriesPoint, len(data.AggregatedDayData))
+	for i, day := range data.AggregatedDayData {
+		res[i] = timeSeriesPoint{
+			Date:         day.Date,
+			Entries:      entriesCount[day.DateInt],
+			Calories:     day.Calories,
+			Protein:      day.Protein,
+			Carbohydrate: day.Carbohydrate,
+			Fat:          day.Fat,
+		}
+	}
+	return res
+}
+
+func getMealTimeSeriesPoints(data *fatsecret.DiaryData) []timeSeriesPoint {
+	type mealKey struct {
+		DateInt int64
+		Meal    string
+	}
+
+	points := map[mealKey]*timeSeriesPoint{}
+	for _, entry := range data.DiaryData {
+		key := mealKey{DateInt: entry.DateInt, Meal: strings.ToLower(entry.Meal)}
+		point, ok := points[key]
+		if !ok {
+			point = &timeSeriesPoint{Date: entry.Date, Meal: key.Meal}
+			points[key] = point
+		}
+		point.Entries++
+		point.Calories += entry.Calories
+		point.Protein += entry.Protein
+		point.Carbohydrate += entry.Carbohydrate
+		point.Fat += entry.Fat
+	}
+
+	res := make([]timeSeriesPoint, 0, len(points))
+	for _, point := range points {
+		res = append(res, *point)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Date.Equal(res[j].Date) {
+			return res[i].Date.Before(res[j].Date)
+		}
+		return res[i].Meal < res[j].Meal
+	})
+	return res
+}
+
+func writeInfluxLine(w *bufio.Writer, measurement string, tags map[string]string, extraTag string, extraTagValue string, point timeSeriesPoint) {
+	// Line protocol has no empty tag values, so such tags are skipped instead of rejecting the batch
+	writeTag := func(name string, val string) {
+		if name != "" && val != "" {
+			_, _ = fmt.Fprintf(w, ",%s=%s", escapeInflux(name, ",= "), escapeInflux(val, ",= "))
+		}
+	}
+
+	_, _ = w.WriteString(escapeInflux(measurement, ", "))
+	for _, name := range sortedKeys(tags) {
+		writeTag(name, tags[name])
+	}
+	writeTag(extraTag, extraTagValue)
+
+	for i, field := range timeSeriesFields {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		_, _ = fmt.Fprintf(w, "%s%s=%s", sep, field.Name, strconv.FormatFloat(field.Value(point), 'f', -1, 64))
+	}
+	_, _ = fmt.Fprintf(w, ",entries=%di %d\n", point.Entries, point.Date.UnixNano())
+}
+
+func writeOpenMetricsFamily(w *bufio.Writer, name string, labels map[string]string, extraLabel string, field timeSeriesField, points []timeSeriesPoint) {
+	if len(points) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	for _, point := range points {
+		var labelParts []string
+		for _, labelName := range sortedKeys(labels) {
+			labelParts = append(labelParts, fmt.Sprintf("%s=\"%s\"", labelName, escapeOpenMetrics(labels[labelName])))
+		}
+		if extraLabel != "" {
+			labelParts = append(labelParts, fmt.Sprintf("%s=\"%s\"", extraLabel, escapeOpenMetrics(point.Meal)))
+		}
+
+		_, _ = w.WriteString(name)
+		if len(labelParts) > 0 {
+			_, _ = fmt.Fprintf(w, "{%s}", strings.Join(labelParts, ","))
+		}
+		_, _ = fmt.Fprintf(w, " %s %d\n", strconv.FormatFloat(field.Value(point), 'f', -1, 64), point.Date.Unix())
+	}
+}
+
+func escapeInflux(val string, chars string) string {
+	val = strings.ReplaceAll(val, "\\", "\\\\")
+	for _, char := range chars {
+		val = strings.ReplaceAll(val, string(char), "\\"+string(char))
+	}
+	return val
+}
+
+func escapeOpenMetrics(val string) string {
+	val = strings.ReplaceAll(val, "\\", "\\\\")
+	val = strings.ReplaceAll(val, "\"", "\\\"")
+	return strings.ReplaceAll(val, "\n", "\\n")
+}
+
+func sortedKeys(vals map[string]string) []string {
+	res := make([]string, 0, len(vals))
+	for key := range vals {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,230 @@
+package exporters
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+const xlsxDateFormat = "yyyy-mm-dd"
+const xlsxNumberFormat = "0.00"
+
+type xlsxStyles struct {
+	header int
+	date   int
+	number int
+}
+
+func WriteDiaryXlsx(w io.Writer, data *fatsecret.DiaryData) error {
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("WARN: XLSX: error when closing workbook: %v", err)
+		}
+	}()
+
+	styles, err := newXlsxStyles(f)
+	if err != nil {
+		return err
+	}
+
+	if err := f.SetSheetName("Sheet1", "Summary"); err != nil {
+		return fmt.Errorf("XLSX: error when creating summary sheet: %v", err)
+	}
+	if err := writeXlsxSummary(f, "Summary", data, styles); err != nil {
+		return err
+	}
+
+	if err := writeXlsxSheet(f, "Days", foodEntryDayColumns, data.AggregatedDayData, styles); err != nil {
+		return err
+	}
+	if err := writeXlsxSheet(f, "Entries", foodEntryColumns, data.DiaryData, styles); err != nil {
+		return err
+	}
+	if len(data.Weights) > 0 {
+		if err := writeXlsxSheet(f, "Weight", weightColumns, data.Weights, styles); err != nil {
+			return err
+		}
+	}
+	if len(data.Exercises) > 0 {
+		if err := writeXlsxSheet(f, "Exercise", exerciseColumns, data.Exercises, styles); err != nil {
+			return err
+		}
+	}
+
+	f.SetActiveSheet(0)
+	if err := f.Write(w); err != nil {
+		return fmt.Errorf("XLSX: error when writing workbook: %v", err)
+	}
+	return nil
+}
+
+func newXlsxStyles(f *excelize.File) (*xlsxStyles, error) {
+	var err error
+	res := &xlsxStyles{}
+
+	if res.header, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#DDEBF7"}},
+	}); err != nil {
+		return nil, fmt.Errorf("XLSX: error when creating header style: %v", err)
+	}
+
+	dateFormat := xlsxDateFormat
+	if res.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return nil, fmt.Errorf("XLSX: error when creating date style: %v", err)
+	}
+
+	numberFormat := xlsxNumberFormat
+	if res.number, err = f.NewStyle(&excelize.Style{CustomNumFmt: &numberFormat}); err != nil {
+		return nil, fmt.Errorf("XLSX: error when creating number style: %v", err)
+	}
+
+	return res, nil
+}
+
+func writeXlsxSheet[T any](f *excelize.File, sheet string, columns []tableColumn[T], items []T, styles *xlsxStyles) error {
+	if _, err := f.NewSheet(sheet); err != nil {
+		return fmt.Errorf("XLSX: error when creating sheet %s: %v", sheet, err)
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	if err := setXlsxRow(f, sheet, 1, header, styles.header); err != nil {
+		return err
+	}
+
+	for rowIdx, item := range items {
+		for colIdx, col := range columns {
+			if err := setXlsxCell(f, sheet, colIdx+1, rowIdx+2, col.Value(item), styles); err != nil {
+				return err
+			}
+		}
+	}
+
+	return finishXlsxSheet(f, sheet, len(columns))
+}
+
+func writeXlsxSummary(f *excelize.File, sheet string, data *fatsecret.DiaryData, styles *xlsxStyles) error {
+	var totals fatsecret.FoodEntryDayData
+	for _, day := range data.AggregatedDayData {
+		totals.Calories += day.Calories
+		totals.Protein += day.Protein
+		totals.Carbohydrate += day.Carbohydrate
+		totals.Fat += day.Fat
+	}
+
+	daysCount := float64(len(data.AggregatedDayData))
+	average := func(val float64) interface{} {
+		if daysCount == 0 {
+			return ""
+		}
+		return val / daysCount
+	}
+
+	rows := [][]interface{}{
+		{"metric", "total", "daily average"},
+		{"from_date", data.FromDate, ""},
+		{"to_date", data.ToDate, ""},
+		{"days", int64(len(data.AggregatedDayData)), ""},
+		{"food_entries", int64(len(data.DiaryData)), ""},
+		{"calories", totals.Calories, average(totals.Calories)},
+		{"protein", totals.Protein, average(totals.Protein)},
+		{"carbohydrate", totals.Carbohydrate, average(totals.Carbohydrate)},
+		{"fat", totals.Fat, average(totals.Fat)},
+	}
+	if len(data.Weights) > 0 {
+		rows = append(rows, []interface{}{"last_weight_kg", data.Weights[len(data.Weights)-1].WeightKg, ""})
+	}
+	if len(data.Exercises) > 0 {
+		var exerciseCalories float64
+		for _, exercise := range data.Exercises {
+			exerciseCalories += exercise.Calories
+		}
+		rows = append(rows, []interface{}{"exercise_calories", exerciseCalories, average(exerciseCalories)})
+	}
+
+	if err := setXlsxRow(f, sheet, 1, rows[0], styles.header); err != nil {
+		return err
+	}
+	for rowIdx, row := range rows[1:] {
+		for colIdx, val := range row {
+			if err := setXlsxCell(f, sheet, colIdx+1, rowIdx+2, val, styles); err != nil {
+				return err
+			}
+		}
+	}
+
+	return finishXlsxSheet(f, sheet, 3)
+}
+
+func setXlsxRow(f *excelize.File, sheet string, rowIdx int, values []interface{}, styleId int) error {
+	firstCell, err := excelize.CoordinatesToCellName(1, rowIdx)
+	if err != nil {
+		return fmt.Errorf("XLSX: invalid cell: %v", err)
+	}
+	lastCell, err := excelize.CoordinatesToCellName(len(values), rowIdx)
+	if err != nil {
+		return fmt.Errorf("XLSX: invalid cell: %v", err)
+	}
+
+	if err := f.SetSheetRow(sheet, firstCell, &values); err != nil {
+		return fmt.Errorf("XLSX: error when writing row to sheet %s: %v", sheet, err)
+	}
+	if err := f.SetCellStyle(sheet, firstCell, lastCell, styleId); err != nil {
+		return fmt.Errorf("XLSX: error when setting row style on sheet %s: %v", sheet, err)
+	}
+	return nil
+}
+
+func setXlsxCell(f *excelize.File, sheet string, colIdx int, rowIdx int, val interface{}, styles *xlsxStyles) error {
+	cell, err := excelize.CoordinatesToCellName(colIdx, rowIdx)
+	if err != nil {
+		return fmt.Errorf("XLSX: invalid cell: %v", err)
+	}
+
+	if err := f.SetCellValue(sheet, cell, val); err != nil {
+		return fmt.Errorf("XLSX: error when writing cell %s on sheet %s: %v", cell, sheet, err)
+	}
+
+	styleId := 0
+	switch val.(type) {
+	case time.Time:
+		styleId = styles.date
+	case float64:
+		styleId = styles.number
+	}
+	if styleId != 0 {
+		if err := f.SetCellStyle(sheet, cell, cell, styleId); err != nil {
+			return fmt.Errorf("XLSX: error when setting style of cell %s on sheet %s: %v", cell, sheet, err)
+		}
+	}
+	return nil
+}
+
+func finishXlsxSheet(f *excelize.File, sheet string, columnsCount int) error {
+	if err := f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return fmt.Errorf("XLSX: error when freezing header of sheet %s: %v", sheet, err)
+	}
+
+	lastColumn, err := excelize.ColumnNumberToName(columnsCount)
+	if err != nil {
+		return fmt.Errorf("XLSX: invalid column: %v", err)
+	}
+	if err := f.SetColWidth(sheet, "A", lastColumn, 16); err != nil {
+		return fmt.Errorf("XLSX: error when setting column width of sheet %s: %v", sheet, err)
+	}
+	return nil
+}
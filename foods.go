@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/akamensky/argparse"
+
+	"github.com/andre487/data-migrators/providers/openfoodfacts"
+	"github.com/andre487/data-migrators/providers/usda"
+	"github.com/andre487/data-migrators/transforms"
+)
+
+type loadFoodsArgs struct {
+	InputPath string
+	DbPath    string
+}
+
+type loadFoodsFlags struct {
+	cmd    *argparse.Command
+	input  *string
+	dbPath *string
+}
+
+func addLoadFoodsCommand(parser *argparse.Parser, name string, help string, inputHelp string) *loadFoodsFlags {
+	cmd := parser.NewCommand(name, help)
+	return &loadFoodsFlags{
+		cmd: cmd,
+		input: cmd.String("i", "input", &argparse.Options{
+			Required: true,
+			Help:     inputHelp,
+		}),
+		dbPath: cmd.String("", "db", &argparse.Options{
+			Help: "Index database path, in the data dir by default",
+		}),
+	}
+}
+
+func (f *loadFoodsFlags) get() loadFoodsArgs {
+	return loadFoodsArgs{InputPath: *f.input, DbPath: *f.dbPath}
+}
+
+type foodLookupArgs struct {
+	Query  string
+	DbPath string
+	Limit  int
+}
+
+type foodLookupFlags struct {
+	cmd    *argparse.Command
+	query  *string
+	dbPath *string
+	limit  *int
+}
+
+func addFoodLookupCommand(parser *argparse.Parser) *foodLookupFlags {
+	cmd := parser.NewCommand("food-lookup", "Look up foods in the local USDA FoodData Central index")
+	return &foodLookupFlags{
+		cmd: cmd,
+		query: cmd.StringPositional(&argparse.Options{
+			Required: true,
+			Help:     "Food name, FDC id or barcode",
+		}),
+		dbPath: cmd.String("", "db", &argparse.Options{
+			Help: "Index database path, in the data dir by default",
+		}),
+		limit: cmd.Int("n", "limit", &argparse.Options{
+			Default: 5,
+			Help:    "Max number of foods to show",
+		}),
+	}
+}
+
+func (f *foodLookupFlags) get() foodLookupArgs {
+	return foodLookupArgs{Query: *f.query, DbPath: *f.dbPath, Limit: *f.limit}
+}
+
+func actionLoadOpenFoodFacts(args cliArgs) {
+	cmdArgs := args.ActionArgs.(loadFoodsArgs)
+	index, err := openfoodfacts.OpenIndex(cmdArgs.DbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := index.Close(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}()
+
+	count, err := index.Load(cmdArgs.InputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Open Food Facts: loaded %d products from %s", count, cmdArgs.InputPath)
+}
+
+func actionLoadUsda(args cliArgs) {
+	cmdArgs := args.ActionArgs.(loadFoodsArgs)
+	index, err := usda.OpenIndex(cmdArgs.DbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := index.Close(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}()
+
+	count, err := index.Load(cmdArgs.InputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("USDA: loaded %d foods from %s", count, cmdArgs.InputPath)
+}
+
+func actionFoodLookup(args cliArgs) {
+	cmdArgs := args.ActionArgs.(foodLookupArgs)
+	index, err := usda.OpenIndex(cmdArgs.DbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := index.Close(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}()
+
+	foods, err := index.Search(cmdArgs.Query, cmdArgs.Limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(foods) == 0 {
+		log.Printf("USDA: there are no foods matching %s", cmdArgs.Query)
+		return
+	}
+
+	for i, food := range foods {
+		if i > 0 {
+			fmt.Println()
+		}
+		title := food.Description
+		if food.Brand != "" {
+			title += ", " + food.Brand
+		}
+		fmt.Printf("FDC %d\t%s\t%s\n", food.FdcId, food.DataType, title)
+		if food.GtinUpc != "" {
+			fmt.Printf("  Barcode: %s\n", food.GtinUpc)
+		}
+		if food.ServingGrams > 0 {
+			fmt.Printf("  Serving: %g g\n", food.ServingGrams)
+		}
+		fmt.Println("  Nutrients per 100 g:")
+		for _, nutrient := range food.Nutrients {
+			fmt.Printf("    %s: %g %s\n", nutrient.Name, nutrient.Amount, nutrient.DisplayUnit())
+		}
+	}
+}
+
+func openDiaryEnrichers(refs []string) ([]transforms.EntryEnricher, func(), error) {
+	var enrichers []transforms.EntryEnricher
+	var closers []func() error
+	closeAll := func() {
+		for _, closeEnricher := range closers {
+			if err := closeEnricher(); err != nil {
+				log.Printf("WARN: %v", err)
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		kind, dbPath, _ := strings.Cut(ref, ":")
+		switch kind {
+		case "openfoodfacts":
+			index, err := openfoodfacts.OpenIndex(dbPath)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			closers = append(closers, index.Close)
+			if count, err := index.Count(); err != nil {
+				closeAll()
+				return nil, nil, err
+			} else if count == 0 {
+				log.Printf("WARN: Open Food Facts: the index is empty, load a dump with load-openfoodfacts")
+			}
+			enrichers = append(enrichers, index)
+		case "usda":
+			index, err := usda.OpenIndex(dbPath)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			closers = append(closers, index.Close)
+			if count, err := index.Count(); err != nil {
+				closeAll()
+				return nil, nil, err
+			} else if count == 0 {
+				log.Printf("WARN: USDA: the index is empty, load a FoodData Central release with load-usda")
+			}
+			enrichers = append(enrichers, index)
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unknown enricher %s", ref)
+		}
+	}
+	return enrichers, closeAll, nil
+}
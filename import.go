@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/akamensky/argparse"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+type importArgs struct {
+	InputPath   string
+	FromDate    time.Time
+	ToDate      time.Time
+	WeightUnit  string
+	MappingPath string
+	Location    *time.Location
+	Output      diaryOutputArgs
+}
+
+type importFlags struct {
+	cmd         *argparse.Command
+	input       *string
+	fromDate    *string
+	toDate      *string
+	weightUnit  *string
+	mappingPath *string
+	timezone    *string
+	output      *diaryOutputFlags
+}
+
+func addImportCommand(parser *argparse.Parser, name string, help string, inputHelp string, defaultName string) *importFlags {
+	cmd := parser.NewCommand(name, help)
+	return &importFlags{
+		cmd:    cmd,
+		output: addDiaryOutputArgs(cmd, defaultName),
+		input: cmd.String("i", "input", &argparse.Options{
+			Required: true,
+			Help:     inputHelp,
+		}),
+		fromDate: cmd.String("m", "from-date", &argparse.Options{
+			Validate: validateOptionalDate,
+			Help:     "Import records from this date, YYYY-MM-DD",
+		}),
+		toDate: cmd.String("t", "to-date", &argparse.Options{
+			Validate: validateOptionalDate,
+			Help:     "Import records up to this date, YYYY-MM-DD",
+		}),
+	}
+}
+
+func (f *importFlags) withWeightUnit(defaultUnit string) *importFlags {
+	f.weightUnit = f.cmd.Selector("", "weight-unit", []string{"kg", "lb"}, &argparse.Options{
+		Default: defaultUnit,
+		Help:    "Unit of weights in the export",
+	})
+	return f
+}
+
+func (f *importFlags) withMapping() *importFlags {
+	f.mappingPath = f.cmd.String("", "mapping", &argparse.Options{
+		Required: true,
+		Help:     "JSON file mapping the columns to diary fields",
+	})
+	return f
+}
+
+func (f *importFlags) withTimezone() *importFlags {
+	f.timezone = f.cmd.String("", "timezone", &argparse.Options{
+		Default: "Local",
+		Help:    "Time zone of the diary days, e.g. America/New_York",
+		Validate: func(val []string) error {
+			_, err := time.LoadLocation(val[0])
+			return err
+		},
+	})
+	return f
+}
+
+func (f *importFlags) get() importArgs {
+	res := importArgs{
+		InputPath: *f.input,
+		FromDate:  parseOptionalDate(f.fromDate),
+		ToDate:    parseOptionalDate(f.toDate),
+		Output:    f.output.get(),
+	}
+	if f.weightUnit != nil {
+		res.WeightUnit = *f.weightUnit
+	}
+	if f.mappingPath != nil {
+		res.MappingPath = *f.mappingPath
+	}
+	if f.timezone != nil {
+		location, err := time.LoadLocation(*f.timezone)
+		if err != nil {
+			log.Fatalf("invalid timezone %s: %v", *f.timezone, err)
+		}
+		res.Location = location
+	}
+	return res
+}
+
+func actionImportDiary(args cliArgs, sourceName string, read func(cmdArgs importArgs) (*fatsecret.DiaryData, error)) {
+	cmdArgs := args.ActionArgs.(importArgs)
+	data, err := read(cmdArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s: read %d days, %d food entries, %d weights, %d exercises",
+		sourceName, len(data.AggregatedDayData), len(data.DiaryData), len(data.Weights), len(data.Exercises))
+
+	source := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, sourceName)
+	targets, err := exportDiary(cmdArgs.Output, source, data.FromDate, data.ToDate, data.Stream)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s: diary was written to %s", sourceName, strings.Join(targets, ", "))
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/akamensky/argparse"
 
+	"github.com/andre487/data-migrators/providers/applehealth"
+	"github.com/andre487/data-migrators/providers/cronometer"
+	"github.com/andre487/data-migrators/providers/csv"
 	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/providers/garmin"
+	"github.com/andre487/data-migrators/providers/googlefit"
+	"github.com/andre487/data-migrators/providers/loseit"
+	"github.com/andre487/data-migrators/providers/myfitnesspal"
+	"github.com/andre487/data-migrators/providers/samsunghealth"
 	"github.com/andre487/data-migrators/utils/secrets"
 )
 
@@ -21,6 +29,83 @@ func main() {
 	case "get-fatsecret-diary":
 		actionGetFatsecretDiary(args)
 		break
+	case "load-openfoodfacts":
+		actionLoadOpenFoodFacts(args)
+		break
+	case "load-usda":
+		actionLoadUsda(args)
+		break
+	case "food-lookup":
+		actionFoodLookup(args)
+		break
+	case "import-apple-health":
+		actionImportDiary(args, "Apple Health", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return applehealth.ReadExport(cmdArgs.InputPath, applehealth.Options{
+				FromDate: cmdArgs.FromDate,
+				ToDate:   cmdArgs.ToDate,
+			})
+		})
+		break
+	case "import-cronometer":
+		actionImportDiary(args, "Cronometer", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return cronometer.ReadExport(cmdArgs.InputPath, cronometer.Options{
+				FromDate: cmdArgs.FromDate,
+				ToDate:   cmdArgs.ToDate,
+			})
+		})
+		break
+	case "import-csv":
+		actionImportDiary(args, "CSV", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return csv.ReadFile(cmdArgs.InputPath, csv.Options{
+				FromDate:    cmdArgs.FromDate,
+				ToDate:      cmdArgs.ToDate,
+				MappingPath: cmdArgs.MappingPath,
+			})
+		})
+		break
+	case "import-garmin-fit":
+		actionImportDiary(args, "Garmin", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return garmin.ReadFitFiles(cmdArgs.InputPath, garmin.Options{
+				FromDate: cmdArgs.FromDate,
+				ToDate:   cmdArgs.ToDate,
+			})
+		})
+		break
+	case "import-google-fit":
+		actionImportDiary(args, "Google Fit", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return googlefit.ReadExport(cmdArgs.InputPath, googlefit.Options{
+				FromDate: cmdArgs.FromDate,
+				ToDate:   cmdArgs.ToDate,
+				Location: cmdArgs.Location,
+			})
+		})
+		break
+	case "import-loseit":
+		actionImportDiary(args, "Lose It!", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return loseit.ReadExport(cmdArgs.InputPath, loseit.Options{
+				FromDate:   cmdArgs.FromDate,
+				ToDate:     cmdArgs.ToDate,
+				WeightUnit: cmdArgs.WeightUnit,
+			})
+		})
+		break
+	case "import-myfitnesspal":
+		actionImportDiary(args, "MyFitnessPal", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return myfitnesspal.ReadExport(cmdArgs.InputPath, myfitnesspal.Options{
+				FromDate:   cmdArgs.FromDate,
+				ToDate:     cmdArgs.ToDate,
+				WeightUnit: cmdArgs.WeightUnit,
+			})
+		})
+		break
+	case "import-samsung-health":
+		actionImportDiary(args, "Samsung Health", func(cmdArgs importArgs) (*fatsecret.DiaryData, error) {
+			return samsunghealth.ReadExport(cmdArgs.InputPath, samsunghealth.Options{
+				FromDate: cmdArgs.FromDate,
+				ToDate:   cmdArgs.ToDate,
+			})
+		})
+		break
 	default:
 		log.Fatalf("Unknown action %s\n", args.Action)
 	}
@@ -28,28 +113,22 @@ func main() {
 
 func actionGetFatsecretDiary(args cliArgs) {
 	cmdArgs := args.ActionArgs.(fsDiaryArgs)
-	keyData, err := secrets.GetSecretFromFile(cmdArgs.KeyFilePath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fs, err := fatsecret.New(keyData)
+	keyData, err := secrets.GetSecret(cmdArgs.KeyRef)
 	if err != nil {
 		log.Fatal(err)
 	}
-	res, err := fs.GetDiary(cmdArgs.FromDate, cmdArgs.ToDate)
+	fs, err := fatsecret.New(keyData, fatsecret.WithAuthCodePrompt(fatsecret.PromptAuthCodeFromStdin))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	jsRes, err := json.Marshal(res)
-	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = cmdArgs.OutFile.Write(jsRes)
+	targets, err := exportDiary(cmdArgs.Output, "fatsecret", cmdArgs.FromDate, cmdArgs.ToDate, func(consumer fatsecret.DiaryConsumer) error {
+		return fs.StreamDiary(cmdArgs.FromDate, cmdArgs.ToDate, consumer)
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Printf("FatSecret: diary was written to file %s", cmdArgs.OutFile.Name())
+	log.Printf("FatSecret: diary was written to %s", strings.Join(targets, ", "))
 }
 
 type cliArgs struct {
@@ -58,31 +137,55 @@ type cliArgs struct {
 }
 
 type fsDiaryArgs struct {
-	KeyFilePath string
-	OutFile     *os.File
-	FromDate    time.Time
-	ToDate      time.Time
+	KeyRef   string
+	FromDate time.Time
+	ToDate   time.Time
+	Output   diaryOutputArgs
 }
 
 func getArgs() cliArgs {
 	parser := argparse.NewParser("data-migrations", "Migrate data for andre487")
 
 	getFsDiaryCommand := parser.NewCommand("get-fatsecret-diary", "Get FatSecret diary")
-	fsDiaryOutFile := getFsDiaryCommand.FilePositional(os.O_CREATE|os.O_WRONLY, 0644, &argparse.Options{
-		Default: "fat-secret-diary-data.json",
-	})
-	fsKeyFilePath := getFsDiaryCommand.String("k", "key-file", &argparse.Options{
-		Default: "~/.tokens/fatsecret.json",
+	fsDiaryOutput := addDiaryOutputArgs(getFsDiaryCommand, "fat-secret-diary-data")
+	fsKeyRef := getFsDiaryCommand.String("k", "key-file", &argparse.Options{
+		Default: getEnvDefault("DM_FATSECRET_KEYS", "file:~/.tokens/fatsecret.json"),
+		Help:    "Keys reference: file:PATH, env:NAME, cmd:COMMAND, inline JSON or a plain path",
 	})
 	fsDiaryFromDate := getFsDiaryCommand.String("m", "from-date", &argparse.Options{
-		Default:  time.Now().AddDate(0, 0, -2).Format("2006-01-01"),
+		Default:  time.Now().AddDate(0, 0, -2).Format(time.DateOnly),
 		Validate: validateDate,
 	})
 	fsDiaryToDate := getFsDiaryCommand.String("t", "to-date", &argparse.Options{
-		Default:  time.Now().Format("2006-01-01"),
+		Default:  time.Now().Format(time.DateOnly),
 		Validate: validateDate,
 	})
 
+	importCommands := []*importFlags{
+		addImportCommand(parser, "import-myfitnesspal", "Import MyFitnessPal data export",
+			"MyFitnessPal export ZIP or a directory with its CSV files", "myfitnesspal-diary-data").withWeightUnit("kg"),
+		addImportCommand(parser, "import-cronometer", "Import Cronometer CSV exports",
+			"ZIP or directory with Cronometer servings, dailysummary, exercises and biometrics CSV files", "cronometer-diary-data"),
+		addImportCommand(parser, "import-apple-health", "Import Apple Health export",
+			"Apple Health export ZIP or its export.xml", "apple-health-diary-data"),
+		addImportCommand(parser, "import-google-fit", "Import Google Fit data from Google Takeout",
+			"Google Takeout ZIP or its Fit directory", "google-fit-diary-data").withTimezone(),
+		addImportCommand(parser, "import-garmin-fit", "Import weight and activities from Garmin FIT files",
+			"Directory or ZIP with FIT files, or a single FIT file", "garmin-diary-data"),
+		addImportCommand(parser, "import-samsung-health", "Import Samsung Health personal data export",
+			"Samsung Health export ZIP or directory", "samsung-health-diary-data"),
+		addImportCommand(parser, "import-loseit", "Import Lose It! data export",
+			"Lose It! export ZIP or a directory with its CSV files", "loseit-diary-data").withWeightUnit("kg"),
+		addImportCommand(parser, "import-csv", "Import any CSV or TSV file with a column mapping",
+			"CSV or TSV file, tab-separated for .tsv by default", "csv-diary-data").withMapping(),
+	}
+
+	loadOffCommand := addLoadFoodsCommand(parser, "load-openfoodfacts", "Load Open Food Facts dump into the local index",
+		"Open Food Facts JSONL or CSV dump, optionally .gz or .zst")
+	loadUsdaCommand := addLoadFoodsCommand(parser, "load-usda", "Load USDA FoodData Central CSV release into the local index",
+		"FoodData Central CSV release ZIP or its directory")
+	foodLookupCommand := addFoodLookupCommand(parser)
+
 	helpCommand := parser.NewCommand("help", "Show help")
 
 	rootUsage := parser.Usage("")
@@ -101,17 +204,42 @@ func getArgs() cliArgs {
 	case getFsDiaryCommand.Happened():
 		res.Action = getFsDiaryCommand.GetName()
 		res.ActionArgs = fsDiaryArgs{
-			KeyFilePath: *fsKeyFilePath,
-			OutFile:     fsDiaryOutFile,
-			FromDate:    parseDate(fsDiaryFromDate),
-			ToDate:      parseDate(fsDiaryToDate),
+			KeyRef:   *fsKeyRef,
+			FromDate: parseDate(fsDiaryFromDate),
+			ToDate:   parseDate(fsDiaryToDate),
+			Output:   fsDiaryOutput.get(),
 		}
 		break
+	case loadOffCommand.cmd.Happened():
+		res.Action = loadOffCommand.cmd.GetName()
+		res.ActionArgs = loadOffCommand.get()
+		break
+	case loadUsdaCommand.cmd.Happened():
+		res.Action = loadUsdaCommand.cmd.GetName()
+		res.ActionArgs = loadUsdaCommand.get()
+		break
+	case foodLookupCommand.cmd.Happened():
+		res.Action = foodLookupCommand.cmd.GetName()
+		res.ActionArgs = foodLookupCommand.get()
+		break
+	}
+	for _, importCommand := range importCommands {
+		if importCommand.cmd.Happened() {
+			res.Action = importCommand.cmd.GetName()
+			res.ActionArgs = importCommand.get()
+		}
 	}
 
 	return res
 }
 
+func getEnvDefault(name string, defaultVal string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
 var dateRe, _ = regexp.Compile("^\\d{4}-\\d{2}-\\d{2}$")
 
 func validateDate(val []string) error {
@@ -122,9 +250,23 @@ func validateDate(val []string) error {
 }
 
 func parseDate(dt *string) time.Time {
-	res, err := time.Parse("2006-01-01", *dt)
+	res, err := time.Parse(time.DateOnly, *dt)
 	if err != nil {
 		log.Fatal(err)
 	}
 	return res
 }
+
+func validateOptionalDate(val []string) error {
+	if val[0] == "" {
+		return nil
+	}
+	return validateDate(val)
+}
+
+func parseOptionalDate(dt *string) time.Time {
+	if *dt == "" {
+		return time.Time{}
+	}
+	return parseDate(dt)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akamensky/argparse"
+
+	"github.com/andre487/data-migrators/exporters"
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/sinks/sqlite"
+	"github.com/andre487/data-migrators/transforms"
+)
+
+var diaryOutputFormats = []string{"json", "ndjson", "csv", "parquet", "xlsx", "influx", "openmetrics", "fhir", "omh", "cronometer", "myfitnesspal", "markdown", "html", "pdf"}
+
+var diaryOutputExtensions = map[string]string{
+	"json":         ".json",
+	"ndjson":       ".ndjson",
+	"csv":          "",
+	"parquet":      "",
+	"xlsx":         ".xlsx",
+	"influx":       ".lp",
+	"openmetrics":  ".prom",
+	"fhir":         ".fhir.json",
+	"omh":          ".omh.json",
+	"cronometer":   "",
+	"myfitnesspal": "",
+	"markdown":     ".md",
+	"html":         ".html",
+	"pdf":          ".pdf",
+}
+
+var diaryDirFormats = map[string]bool{
+	"csv":          true,
+	"parquet":      true,
+	"cronometer":   true,
+	"myfitnesspal": true,
+}
+
+// These formats compress their data themselves and readers can't open them compressed once more
+var diaryCompressedFormats = map[string]bool{
+	"parquet": true,
+	"xlsx":    true,
+}
+
+var diaryStreamFormats = map[string]bool{
+	"ndjson": true,
+}
+
+type diaryOutputArgs struct {
+	OutPath      string
+	Format       string
+	Compression  exporters.Compression
+	ShardByMonth bool
+	Sink         string
+	Enrich       []string
+	Csv          exporters.CsvOptions
+	Parquet      exporters.ParquetOptions
+	TimeSeries   exporters.TimeSeriesOptions
+	Fhir         exporters.FhirOptions
+	Pdf          exporters.PdfOptions
+}
+
+type diaryOutputFlags struct {
+	defaultName     string
+	outPath         *string
+	format          *string
+	compress        *string
+	shardByMonth    *bool
+	sink            *string
+	enrich          *[]string
+	csvEntryColumns *string
+	csvDayColumns   *string
+	csvDelimiter    *string
+	csvDecimal      *string
+	csvPrecision    *int
+	parquetByMonth  *bool
+	tsDayName       *string
+	tsMealName      *string
+	tsMealTag       *string
+	tsTags          *string
+	fhirSubject     *string
+	pdfFont         *string
+}
+
+func addDiaryOutputArgs(cmd *argparse.Command, defaultName string) *diaryOutputFlags {
+	return &diaryOutputFlags{
+		defaultName: defaultName,
+		outPath: cmd.StringPositional(&argparse.Options{
+			Help: "Output path or - for stdout, default depends on format: " + defaultName + "[.ext]",
+		}),
+		format: cmd.Selector("f", "format", diaryOutputFormats, &argparse.Options{
+			Default: "json",
+			Help:    "Output format",
+		}),
+		compress: cmd.Selector("", "compress", exporters.Compressions, &argparse.Options{
+			Help: "Output compression, detected by .gz or .zst extension by default; compresses every file of directory formats, not for parquet and xlsx",
+		}),
+		shardByMonth: cmd.Flag("", "shard-by-month", &argparse.Options{
+			Help: "Write the output path as a directory with a file per month and manifest.json",
+		}),
+		sink: cmd.String("", "sink", &argparse.Options{
+			Help: "Additional data sink, e.g. sqlite:path.db; the output file is skipped when only a sink is set",
+		}),
+		enrich: cmd.StringList("", "enrich", &argparse.Options{
+			Help: "Fill missing nutrients of food entries from a local food database, e.g. openfoodfacts, usda or usda:path.db",
+		}),
+		csvEntryColumns: cmd.String("", "csv-entry-columns", &argparse.Options{
+			Help: "Comma-separated columns of entries.csv, all by default",
+		}),
+		csvDayColumns: cmd.String("", "csv-day-columns", &argparse.Options{
+			Help: "Comma-separated columns of days.csv, all by default",
+		}),
+		csvDelimiter: cmd.String("", "csv-delimiter", &argparse.Options{
+			Default: ",",
+			Help:    "CSV fields delimiter, use \\t for tab",
+		}),
+		csvDecimal: cmd.String("", "csv-decimal", &argparse.Options{
+			Default: ".",
+			Help:    "CSV decimal separator",
+		}),
+		csvPrecision: cmd.Int("", "csv-precision", &argparse.Options{
+			Default: -1,
+			Help:    "CSV digits after the decimal separator, -1 for the shortest representation",
+		}),
+		parquetByMonth: cmd.Flag("", "parquet-partition-by-month", &argparse.Options{
+			Help: "Write Parquet files partitioned as year=YYYY/month=MM",
+		}),
+		tsDayName: cmd.String("", "ts-day-measurement", &argparse.Options{
+			Default: "nutrition_daily",
+			Help:    "InfluxDB measurement or OpenMetrics prefix for daily aggregates",
+		}),
+		tsMealName: cmd.String("", "ts-meal-measurement", &argparse.Options{
+			Default: "nutrition_meal",
+			Help:    "InfluxDB measurement or OpenMetrics prefix for per-meal totals",
+		}),
+		tsMealTag: cmd.String("", "ts-meal-tag", &argparse.Options{
+			Default: "meal",
+			Help:    "Tag or label name for meals",
+		}),
+		tsTags: cmd.String("", "ts-tags", &argparse.Options{
+			Help: "Comma-separated constant tags or labels, e.g. user=me,source=fatsecret",
+		}),
+		fhirSubject: cmd.String("", "fhir-subject", &argparse.Options{
+			Help: "FHIR Observation subject reference, e.g. Patient/123",
+		}),
+		pdfFont: cmd.String("", "pdf-font", &argparse.Options{
+			Help: "TrueType font for PDF reports, needed for characters outside of Windows-1252",
+		}),
+	}
+}
+
+func (f *diaryOutputFlags) get() diaryOutputArgs {
+	res := diaryOutputArgs{
+		OutPath:      *f.outPath,
+		Format:       *f.format,
+		Compression:  exporters.Compression(*f.compress),
+		ShardByMonth: *f.shardByMonth,
+		Sink:         *f.sink,
+		Enrich:       *f.enrich,
+		Csv:          exporters.DefaultCsvOptions(),
+	}
+	if res.OutPath == "" && res.Sink == "" {
+		res.OutPath = f.defaultName
+		if !diaryDirFormats[res.Format] && !res.ShardByMonth {
+			res.OutPath += diaryOutputExtensions[res.Format] + res.Compression.Ext()
+		}
+	}
+	if res.ShardByMonth && diaryDirFormats[res.Format] {
+		log.Fatalf("output format %s writes a directory and can't be sharded by month", res.Format)
+	}
+	if res.Compression == exporters.NoCompression && !diaryDirFormats[res.Format] && !res.ShardByMonth {
+		res.Compression = exporters.CompressionFromPath(res.OutPath)
+	}
+	if res.Compression != exporters.NoCompression && diaryCompressedFormats[res.Format] {
+		log.Fatalf("output format %s is compressed internally and can't be compressed with %s", res.Format, res.Compression)
+	}
+	if res.OutPath == exporters.StdoutPath && (diaryDirFormats[res.Format] || res.ShardByMonth) {
+		log.Fatalf("output format %s writes a directory and can't be written to stdout", res.Format)
+	}
+
+	res.Csv.EntryColumns = splitList(*f.csvEntryColumns)
+	res.Csv.DayColumns = splitList(*f.csvDayColumns)
+	res.Csv.DecimalSeparator = *f.csvDecimal
+	res.Csv.Precision = *f.csvPrecision
+
+	delimiter := strings.Replace(*f.csvDelimiter, "\\t", "\t", 1)
+	if utf8.RuneCountInString(delimiter) != 1 {
+		log.Fatalf("CSV delimiter should be a single character, not %s", delimiter)
+	}
+	res.Csv.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+
+	res.Parquet.PartitionByMonth = *f.parquetByMonth
+
+	res.TimeSeries = exporters.DefaultTimeSeriesOptions()
+	res.TimeSeries.DayMeasurement = *f.tsDayName
+	res.TimeSeries.MealMeasurement = *f.tsMealName
+	res.TimeSeries.MealTag = *f.tsMealTag
+	for _, tag := range splitList(*f.tsTags) {
+		name, val, ok := strings.Cut(tag, "=")
+		if !ok {
+			log.Fatalf("time series tag should be NAME=VALUE, not %s", tag)
+		}
+		res.TimeSeries.Tags[name] = val
+	}
+
+	res.Fhir.Subject = *f.fhirSubject
+	res.Pdf.FontPath = *f.pdfFont
+
+	return res
+}
+
+func exportDiary(args diaryOutputArgs, source string, fromDate time.Time, toDate time.Time, stream func(consumer fatsecret.DiaryConsumer) error) ([]string, error) {
+	var targets []string
+	var consumers fatsecret.DiaryConsumers
+
+	enrichers, closeEnrichers, err := openDiaryEnrichers(args.Enrich)
+	if err != nil {
+		return nil, err
+	}
+	defer closeEnrichers()
+	if len(enrichers) > 0 {
+		sourceStream := stream
+		stream = func(consumer fatsecret.DiaryConsumer) error {
+			enrichConsumer := transforms.NewEnrichConsumer(consumer, enrichers...)
+			if err := sourceStream(enrichConsumer); err != nil {
+				return err
+			}
+			enrichConsumer.LogStats()
+			return nil
+		}
+	}
+
+	var sink *sqlite.Sink
+	if args.Sink != "" {
+		if sink, err = openDiarySink(args.Sink); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := sink.Close(); err != nil {
+				log.Printf("WARN: %v", err)
+			}
+		}()
+		if err := sink.BeginRun(source, fromDate, toDate); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, sink)
+		targets = append(targets, args.Sink)
+	}
+
+	switch {
+	case args.OutPath == "":
+		err = stream(consumers)
+	case diaryStreamFormats[args.Format] && !args.ShardByMonth:
+		_, err = streamDiaryOutput(args, func(consumer fatsecret.DiaryConsumer) error {
+			return stream(append(consumers, consumer))
+		})
+	default:
+		data := &fatsecret.DiaryData{FromDate: fromDate, ToDate: toDate}
+		if err = stream(append(consumers, data)); err == nil {
+			_, err = writeDiaryOutput(data, args)
+		}
+	}
+	if args.OutPath != "" {
+		targets = append(targets, args.OutPath)
+	}
+
+	if sink != nil {
+		err = sink.FinishRun(err)
+	}
+	return targets, err
+}
+
+func openDiarySink(ref string) (*sqlite.Sink, error) {
+	kind, sinkPath, _ := strings.Cut(ref, ":")
+	switch kind {
+	case "sqlite":
+		if sinkPath == "" {
+			return nil, fmt.Errorf("SQLite sink path is empty")
+		}
+		return sqlite.Open(sinkPath)
+	default:
+		return nil, fmt.Errorf("unknown sink %s", ref)
+	}
+}
+
+func writeDiaryOutput(data *fatsecret.DiaryData, args diaryOutputArgs) (string, error) {
+	switch args.Format {
+	case "csv":
+		return args.OutPath, exporters.WriteDiaryCsv(diaryOutputDir(args), data, args.Csv)
+	case "parquet":
+		return args.OutPath, exporters.WriteDiaryParquet(diaryOutputDir(args), data, args.Parquet)
+	case "cronometer":
+		return args.OutPath, exporters.WriteDiaryCronometerCsv(diaryOutputDir(args), data)
+	case "myfitnesspal":
+		return args.OutPath, exporters.WriteDiaryMyFitnessPalCsv(diaryOutputDir(args), data)
+	}
+
+	write, err := getDiaryFileWriter(args)
+	if err != nil {
+		return "", err
+	}
+	if args.ShardByMonth {
+		return args.OutPath, exporters.WriteDiaryShards(diaryOutputDir(args), data, args.Format, diaryOutputExtensions[args.Format], write)
+	}
+	return args.OutPath, exporters.WriteFile(args.OutPath, args.Compression, func(w io.Writer) error {
+		return write(w, data)
+	})
+}
+
+func getDiaryFileWriter(args diaryOutputArgs) (func(w io.Writer, data *fatsecret.DiaryData) error, error) {
+	switch args.Format {
+	case "json":
+		return func(w io.Writer, data *fatsecret.DiaryData) error {
+			jsRes, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(jsRes)
+			return err
+		}, nil
+	case "ndjson":
+		return exporters.WriteDiaryNdjson, nil
+	case "xlsx":
+		return exporters.WriteDiaryXlsx, nil
+	case "influx":
+		return func(w io.Writer, data *fatsecret.DiaryData) error {
+			return exporters.WriteDiaryInfluxLineProtocol(w, data, args.TimeSeries)
+		}, nil
+	case "openmetrics":
+		return func(w io.Writer, data *fatsecret.DiaryData) error {
+			return exporters.WriteDiaryOpenMetrics(w, data, args.TimeSeries)
+		}, nil
+	case "fhir":
+		return func(w io.Writer, data *fatsecret.DiaryData) error {
+			return exporters.WriteDiaryFhirBundle(w, data, args.Fhir)
+		}, nil
+	case "omh":
+		return exporters.WriteDiaryOpenMHealth, nil
+	case "markdown":
+		return exporters.WriteDiaryMarkdownReport, nil
+	case "html":
+		return exporters.WriteDiaryHtmlReport, nil
+	case "pdf":
+		return func(w io.Writer, data *fatsecret.DiaryData) error {
+			return exporters.WriteDiaryPdfReport(w, data, args.Pdf)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %s", args.Format)
+	}
+}
+
+func streamDiaryOutput(args diaryOutputArgs, stream func(consumer fatsecret.DiaryConsumer) error) (string, error) {
+	switch args.Format {
+	case "ndjson":
+		return args.OutPath, exporters.WriteFile(args.OutPath, args.Compression, func(w io.Writer) error {
+			return stream(exporters.NewNdjsonWriter(w))
+		})
+	default:
+		return "", fmt.Errorf("output format %s doesn't support streaming", args.Format)
+	}
+}
+
+func diaryOutputDir(args diaryOutputArgs) exporters.OutputDir {
+	return exporters.OutputDir{Path: args.OutPath, Compression: args.Compression}
+}
+
+func splitList(val string) []string {
+	var res []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,331 @@
+package applehealth
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "applehealth"
+const dietaryTypePrefix = "HKQuantityTypeIdentifierDietary"
+const bodyMassType = "HKQuantityTypeIdentifierBodyMass"
+const workoutTypePrefix = "HKWorkoutActivityType"
+
+type Options struct {
+	FromDate time.Time
+	ToDate   time.Time
+}
+
+type healthRecord struct {
+	Type       string `xml:"type,attr"`
+	SourceName string `xml:"sourceName,attr"`
+	Unit       string `xml:"unit,attr"`
+	Value      string `xml:"value,attr"`
+	StartDate  string `xml:"startDate,attr"`
+}
+
+type healthWorkout struct {
+	ActivityType          string                    `xml:"workoutActivityType,attr"`
+	Duration              string                    `xml:"duration,attr"`
+	DurationUnit          string                    `xml:"durationUnit,attr"`
+	TotalDistance         string                    `xml:"totalDistance,attr"`
+	TotalDistanceUnit     string                    `xml:"totalDistanceUnit,attr"`
+	TotalEnergyBurned     string                    `xml:"totalEnergyBurned,attr"`
+	TotalEnergyBurnedUnit string                    `xml:"totalEnergyBurnedUnit,attr"`
+	StartDate             string                    `xml:"startDate,attr"`
+	Statistics            []healthWorkoutStatistics `xml:"WorkoutStatistics"`
+}
+
+type healthWorkoutStatistics struct {
+	Type string `xml:"type,attr"`
+	Sum  string `xml:"sum,attr"`
+	Unit string `xml:"unit,attr"`
+}
+
+type dietaryNutrient struct {
+	Nutrient string
+	Unit     string
+	Scale    float64
+}
+
+var dietaryNutrients = map[string]dietaryNutrient{
+	"EnergyConsumed":     {"Calories", "kcal", 1},
+	"Protein":            {"Protein", "g", 1},
+	"Carbohydrates":      {"Carbohydrate", "g", 1},
+	"FatTotal":           {"Fat", "g", 1},
+	"Fiber":              {"Fiber", "g", 1},
+	"Sugar":              {"Sugar", "g", 1},
+	"FatSaturated":       {"SaturatedFat", "g", 1},
+	"FatMonounsaturated": {"MonounsaturatedFat", "g", 1},
+	"FatPolyunsaturated": {"PolyunsaturatedFat", "g", 1},
+	"Cholesterol":        {"Cholesterol", "mg", 1},
+	"Sodium":             {"Sodium", "mg", 1},
+	"Potassium":          {"Potassium", "mg", 1},
+	"Calcium":            {"Calcium", "mg", 100.0 / fatsecret.DailyValueCalciumMg},
+	"Iron":               {"Iron", "mg", 100.0 / fatsecret.DailyValueIronMg},
+	"VitaminC":           {"VitaminC", "mg", 100.0 / fatsecret.DailyValueVitaminCMg},
+}
+
+// HealthKit unit strings are case-sensitive: "Cal" is a kilocalorie while "cal" is a small calorie
+var unitScales = map[string]map[string]float64{
+	"kcal": {"kcal": 1, "Cal": 1, "cal": 0.001, "kJ": 1 / 4.184},
+	"g":    {"g": 1, "mg": 0.001, "mcg": 0.000001, "µg": 0.000001, "kg": 1000},
+	"mg":   {"mg": 1, "g": 1000, "mcg": 0.001, "µg": 0.001},
+	"kg":   {"kg": 1, "lb": 0.45359237, "g": 0.001, "st": 6.35029318},
+	"km":   {"km": 1, "m": 0.001, "mi": 1.609344, "yd": 0.0009144, "ft": 0.0003048},
+	"min":  {"min": 1, "s": 1.0 / 60, "hr": 60, "h": 60},
+}
+
+type dayEntry struct {
+	entry   fatsecret.FoodEntryData
+	present map[string]bool
+}
+
+type reader struct {
+	builder *import_util.DiaryBuilder
+	entries map[string]*dayEntry
+}
+
+func ReadExport(exportPath string, opts Options) (*fatsecret.DiaryData, error) {
+	xmlReader, closeExport, err := openExportXml(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("Apple Health: %v", err)
+	}
+	defer func() {
+		_ = closeExport()
+	}()
+
+	r := &reader{
+		builder: import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate),
+		entries: map[string]*dayEntry{},
+	}
+	if err := r.read(xmlReader); err != nil {
+		return nil, fmt.Errorf("Apple Health: %v", err)
+	}
+
+	keys := make([]string, 0, len(r.entries))
+	for key := range r.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		item := r.entries[key]
+		for _, name := range fatsecret.NutrientNames {
+			if !item.present[name] {
+				item.entry.MissingNutrients = append(item.entry.MissingNutrients, name)
+			}
+		}
+		r.builder.AddEntry(item.entry)
+	}
+
+	return r.builder.Build(), nil
+}
+
+func openExportXml(exportPath string) (io.Reader, func() error, error) {
+	if strings.HasSuffix(strings.ToLower(exportPath), ".xml") {
+		fp, err := os.Open(exportPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error when opening export: %v", err)
+		}
+		return fp, fp.Close, nil
+	}
+
+	archive, err := zip.OpenReader(exportPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when opening export ZIP %s: %v", exportPath, err)
+	}
+	for _, file := range archive.File {
+		if path.Base(file.Name) != "export.xml" {
+			continue
+		}
+		fp, err := file.Open()
+		if err != nil {
+			_ = archive.Close()
+			return nil, nil, fmt.Errorf("error when opening %s: %v", file.Name, err)
+		}
+		return fp, func() error {
+			return errors.Join(fp.Close(), archive.Close())
+		}, nil
+	}
+
+	_ = archive.Close()
+	return nil, nil, fmt.Errorf("there is no export.xml in %s", exportPath)
+}
+
+func (r *reader) read(xmlReader io.Reader) error {
+	decoder := xml.NewDecoder(xmlReader)
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error when reading export.xml: %v", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "Record":
+			record := healthRecord{}
+			if err := decoder.DecodeElement(&record, &start); err != nil {
+				return fmt.Errorf("error when decoding record: %v", err)
+			}
+			if err := r.readRecord(record); err != nil {
+				log.Printf("WARN: Apple Health: skip %s record: %v", record.Type, err)
+			}
+		case "Workout":
+			workout := healthWorkout{}
+			if err := decoder.DecodeElement(&workout, &start); err != nil {
+				return fmt.Errorf("error when decoding workout: %v", err)
+			}
+			if err := r.readWorkout(workout); err != nil {
+				log.Printf("WARN: Apple Health: skip %s workout: %v", workout.ActivityType, err)
+			}
+		}
+	}
+}
+
+func (r *reader) readRecord(record healthRecord) error {
+	if record.Type != bodyMassType && !strings.HasPrefix(record.Type, dietaryTypePrefix) {
+		return nil
+	}
+
+	date, err := import_util.ParseDate(record.StartDate)
+	if err != nil {
+		return err
+	}
+	if !r.builder.InRange(date) {
+		return nil
+	}
+	value, err := strconv.ParseFloat(record.Value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %s: %v", record.Value, err)
+	}
+
+	if record.Type == bodyMassType {
+		weight, err := convertUnit(value, record.Unit, "kg")
+		if err != nil {
+			return err
+		}
+		r.builder.AddWeight(fatsecret.WeightData{Date: date, WeightKg: weight})
+		return nil
+	}
+
+	item := r.getDayEntry(date, record.SourceName)
+	name := strings.TrimPrefix(record.Type, dietaryTypePrefix)
+	nutrient, ok := dietaryNutrients[name]
+	if !ok {
+		if item.entry.OtherNutrients == nil {
+			item.entry.OtherNutrients = map[string]float64{}
+		}
+		item.entry.OtherNutrients[fmt.Sprintf("%s (%s)", name, record.Unit)] += value
+		return nil
+	}
+
+	value, err = convertUnit(value, record.Unit, nutrient.Unit)
+	if err != nil {
+		return err
+	}
+	*item.entry.NutrientField(nutrient.Nutrient) += value * nutrient.Scale
+	item.present[nutrient.Nutrient] = true
+	return nil
+}
+
+func (r *reader) getDayEntry(date time.Time, sourceName string) *dayEntry {
+	_, day := import_util.DayDate(date)
+	key := day.Format(time.DateOnly) + "\x00" + sourceName
+
+	item, ok := r.entries[key]
+	if !ok {
+		item = &dayEntry{
+			entry: fatsecret.FoodEntryData{
+				Date:                 day,
+				FoodId:               import_util.SyntheticId(source, "source", sourceName),
+				FoodEntryId:          import_util.SyntheticId(source, "entry", key),
+				FoodEntryName:        sourceName,
+				FoodEntryDescription: "Apple Health daily total",
+				NumberOfUnits:        1,
+				Meal:                 import_util.NormalizeMeal(""),
+			},
+			present: map[string]bool{},
+		}
+		r.entries[key] = item
+	}
+	return item
+}
+
+func (r *reader) readWorkout(workout healthWorkout) error {
+	date, err := import_util.ParseDate(workout.StartDate)
+	if err != nil {
+		return err
+	}
+	if !r.builder.InRange(date) {
+		return nil
+	}
+
+	exercise := fatsecret.ExerciseData{
+		Date: date,
+		Name: strings.TrimPrefix(workout.ActivityType, workoutTypePrefix),
+	}
+	if exercise.DurationMinutes, err = parseQuantity(workout.Duration, workout.DurationUnit, "min"); err != nil {
+		return err
+	}
+	if exercise.DistanceKm, err = parseQuantity(workout.TotalDistance, workout.TotalDistanceUnit, "km"); err != nil {
+		return err
+	}
+	if exercise.Calories, err = parseQuantity(workout.TotalEnergyBurned, workout.TotalEnergyBurnedUnit, "kcal"); err != nil {
+		return err
+	}
+
+	for _, stat := range workout.Statistics {
+		switch {
+		case exercise.Calories == 0 && stat.Type == "HKQuantityTypeIdentifierActiveEnergyBurned":
+			if exercise.Calories, err = parseQuantity(stat.Sum, stat.Unit, "kcal"); err != nil {
+				return err
+			}
+		case exercise.DistanceKm == 0 && strings.HasPrefix(stat.Type, "HKQuantityTypeIdentifierDistance"):
+			if exercise.DistanceKm, err = parseQuantity(stat.Sum, stat.Unit, "km"); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.builder.AddExercise(exercise)
+	return nil
+}
+
+func parseQuantity(value string, unit string, targetUnit string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	res, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %s: %v", value, err)
+	}
+	return convertUnit(res, unit, targetUnit)
+}
+
+func convertUnit(value float64, unit string, targetUnit string) (float64, error) {
+	scale, ok := unitScales[targetUnit][unit]
+	if !ok {
+		return 0, fmt.Errorf("can't convert %s to %s", unit, targetUnit)
+	}
+	return value * scale, nil
+}
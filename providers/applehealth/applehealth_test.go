@@ -0,0 +1,30 @@
+package applehealth
+
+import "testing"
+
+func TestConvertUnit(t *testing.T) {
+	cases := []struct {
+		unit     string
+		target   string
+		expected float64
+	}{
+		{"kcal", "kcal", 250},
+		{"Cal", "kcal", 250},
+		{"cal", "kcal", 0.25},
+		{"kJ", "kcal", 250 / 4.184},
+		{"mg", "g", 0.25},
+		{"lb", "kg", 250 * 0.45359237},
+	}
+	for _, item := range cases {
+		res, err := convertUnit(250, item.unit, item.target)
+		if err != nil {
+			t.Errorf("%s: %v", item.unit, err)
+		} else if res != item.expected {
+			t.Errorf("%s: expected %v %s, got %v", item.unit, item.expected, item.target, res)
+		}
+	}
+
+	if _, err := convertUnit(250, "KCAL", "kcal"); err == nil {
+		t.Errorf("expected an error for an unknown unit")
+	}
+}
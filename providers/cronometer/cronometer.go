@@ -0,0 +1,258 @@
+package cronometer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "cronometer"
+
+type Options struct {
+	FromDate time.Time
+	ToDate   time.Time
+}
+
+var nutrientColumns = []import_util.NutrientColumn{
+	{Nutrient: "Calories", Columns: []string{"Energy (kcal)"}},
+	{Nutrient: "Protein", Columns: []string{"Protein (g)"}},
+	{Nutrient: "Carbohydrate", Columns: []string{"Carbs (g)"}},
+	{Nutrient: "Fat", Columns: []string{"Fat (g)"}},
+	{Nutrient: "Fiber", Columns: []string{"Fiber (g)"}},
+	{Nutrient: "Sugar", Columns: []string{"Sugars (g)"}},
+	{Nutrient: "SaturatedFat", Columns: []string{"Saturated (g)"}},
+	{Nutrient: "MonounsaturatedFat", Columns: []string{"Monounsaturated (g)"}},
+	{Nutrient: "PolyunsaturatedFat", Columns: []string{"Polyunsaturated (g)"}},
+	{Nutrient: "TransFat", Columns: []string{"Trans-Fats (g)"}},
+	{Nutrient: "Cholesterol", Columns: []string{"Cholesterol (mg)"}},
+	{Nutrient: "Sodium", Columns: []string{"Sodium (mg)"}},
+	{Nutrient: "Potassium", Columns: []string{"Potassium (mg)"}},
+	{Nutrient: "Calcium", Columns: []string{"Calcium (mg)"}, Scale: 100.0 / fatsecret.DailyValueCalciumMg},
+	{Nutrient: "Iron", Columns: []string{"Iron (mg)"}, Scale: 100.0 / fatsecret.DailyValueIronMg},
+	{Nutrient: "VitaminC", Columns: []string{"Vitamin C (mg)"}, Scale: 100.0 / fatsecret.DailyValueVitaminCMg},
+}
+
+var servingTextColumns = map[string]bool{
+	"day":       true,
+	"date":      true,
+	"time":      true,
+	"group":     true,
+	"food name": true,
+	"amount":    true,
+	"category":  true,
+	"completed": true,
+}
+
+var knownNutrientColumns = getKnownNutrientColumns()
+
+func getKnownNutrientColumns() map[string]bool {
+	res := map[string]bool{}
+	for _, col := range nutrientColumns {
+		for _, name := range col.Columns {
+			res[strings.ToLower(name)] = true
+		}
+	}
+	return res
+}
+
+func ReadExport(exportPath string, opts Options) (*fatsecret.DiaryData, error) {
+	fsys, closeArchive, err := import_util.OpenArchive(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("Cronometer: %v", err)
+	}
+	defer func() {
+		_ = closeArchive()
+	}()
+
+	builder := import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate)
+	entryIds := map[string]int{}
+	readers := []struct {
+		Match func(name string) bool
+		Read  func(rec import_util.CsvRecord) error
+	}{
+		{
+			func(name string) bool { return strings.Contains(name, "servings") },
+			func(rec import_util.CsvRecord) error { return readServingRecord(builder, rec, entryIds) },
+		},
+		{
+			func(name string) bool {
+				return strings.Contains(name, "dailysummary") || strings.Contains(name, "daily nutrition")
+			},
+			func(rec import_util.CsvRecord) error { return readDailySummaryRecord(builder, rec) },
+		},
+		{
+			func(name string) bool { return strings.Contains(name, "exercises") },
+			func(rec import_util.CsvRecord) error { return readExerciseRecord(builder, rec) },
+		},
+		{
+			func(name string) bool { return strings.Contains(name, "biometrics") },
+			func(rec import_util.CsvRecord) error { return readBiometricRecord(builder, rec) },
+		},
+	}
+
+	filesCount := 0
+	for _, reader := range readers {
+		files, err := import_util.FindFiles(fsys, func(name string) bool {
+			return strings.HasSuffix(name, ".csv") && reader.Match(name)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Cronometer: %v", err)
+		}
+		for _, filePath := range files {
+			if err := import_util.ReadCsvFile(fsys, filePath, ',', reader.Read); err != nil {
+				return nil, fmt.Errorf("Cronometer: %v", err)
+			}
+			filesCount++
+		}
+	}
+	if filesCount == 0 {
+		return nil, fmt.Errorf("Cronometer: there are no servings, daily summary, exercises or biometrics CSV files in %s", exportPath)
+	}
+
+	return builder.Build(), nil
+}
+
+func readServingRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord, entryIds map[string]int) error {
+	date, err := import_util.ParseDate(rec.Get("Day", "Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+	if !builder.InRange(date) {
+		return nil
+	}
+
+	name := rec.Get("Food Name")
+	group := rec.Get("Group")
+	amount := rec.Get("Amount")
+	entry := fatsecret.FoodEntryData{
+		Date:                 date,
+		FoodId:               import_util.SyntheticId(source, "food", name),
+		FoodEntryName:        name,
+		FoodEntryDescription: amount,
+		NumberOfUnits:        parseAmountUnits(amount),
+		Meal:                 import_util.NormalizeMeal(group),
+	}
+
+	entryKey := strings.Join([]string{date.Format(time.DateOnly), rec.Get("Time"), group, name, amount}, "\x00")
+	entry.FoodEntryId = import_util.SyntheticId(source, "entry", entryKey, strconv.Itoa(entryIds[entryKey]))
+	entryIds[entryKey]++
+
+	if err := import_util.ReadNutrients(rec, &entry, nutrientColumns); err != nil {
+		return err
+	}
+	entry.MissingNutrients = append(entry.MissingNutrients, "VitaminA")
+	entry.OtherNutrients = readOtherNutrients(rec)
+
+	builder.AddEntry(entry)
+	return nil
+}
+
+func readDailySummaryRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	date, err := import_util.ParseDate(rec.Get("Date", "Day"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	day := fatsecret.FoodEntryDayData{Date: date}
+	for _, field := range []struct {
+		Column string
+		Value  *float64
+	}{
+		{"Energy (kcal)", &day.Calories},
+		{"Protein (g)", &day.Protein},
+		{"Carbs (g)", &day.Carbohydrate},
+		{"Fat (g)", &day.Fat},
+	} {
+		if *field.Value, _, err = rec.Float(field.Column); err != nil {
+			return err
+		}
+	}
+
+	builder.SetDay(day)
+	return nil
+}
+
+func readExerciseRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	date, err := import_util.ParseDate(rec.Get("Day", "Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	exercise := fatsecret.ExerciseData{
+		Date: date,
+		Name: rec.Get("Exercise"),
+	}
+	if exercise.DurationMinutes, _, err = rec.Float("Minutes"); err != nil {
+		return err
+	}
+	calories, _, err := rec.Float("Calories Burned")
+	if err != nil {
+		return err
+	}
+	exercise.Calories = math.Abs(calories)
+
+	builder.AddExercise(exercise)
+	return nil
+}
+
+func readBiometricRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	if !strings.EqualFold(rec.Get("Metric"), "Weight") {
+		return nil
+	}
+
+	date, err := import_util.ParseDate(rec.Get("Day", "Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+	weight, ok, err := rec.Float("Amount")
+	if err != nil || !ok {
+		return err
+	}
+
+	switch strings.ToLower(rec.Get("Unit")) {
+	case "kg", "":
+	case "lbs", "lb":
+		weight *= import_util.PoundKg
+	default:
+		return fmt.Errorf("line %d: unknown weight unit %s", rec.Line, rec.Get("Unit"))
+	}
+
+	builder.AddWeight(fatsecret.WeightData{Date: date, WeightKg: weight})
+	return nil
+}
+
+// Extra columns are optional, so cells that are not numbers are skipped
+func readOtherNutrients(rec import_util.CsvRecord) map[string]float64 {
+	var res map[string]float64
+	for _, column := range rec.Columns() {
+		if servingTextColumns[strings.ToLower(column)] || knownNutrientColumns[strings.ToLower(column)] {
+			continue
+		}
+		val, ok, err := rec.Float(column)
+		if err != nil || !ok {
+			continue
+		}
+		if res == nil {
+			res = map[string]float64{}
+		}
+		res[column] = val
+	}
+	return res
+}
+
+func parseAmountUnits(amount string) float64 {
+	fields := strings.Fields(amount)
+	if len(fields) == 0 {
+		return 1
+	}
+	res, err := strconv.ParseFloat(strings.ReplaceAll(fields[0], ",", ""), 64)
+	if err != nil {
+		return 1
+	}
+	return res
+}
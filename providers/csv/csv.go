@@ -0,0 +1,439 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "csv"
+
+type Options struct {
+	FromDate    time.Time
+	ToDate      time.Time
+	MappingPath string
+}
+
+type mapping struct {
+	Delimiter   string                   `json:"delimiter"`
+	Computed    map[string]string        `json:"computed"`
+	Id          *fieldMapping            `json:"id"`
+	Date        *fieldMapping            `json:"date"`
+	Meal        *fieldMapping            `json:"meal"`
+	Name        *fieldMapping            `json:"name"`
+	Description *fieldMapping            `json:"description"`
+	Quantity    *fieldMapping            `json:"quantity"`
+	Nutrients   map[string]*fieldMapping `json:"nutrients"`
+	Other       map[string]*fieldMapping `json:"other"`
+	computed    map[string]exprNode
+}
+
+type fieldMapping struct {
+	Column   string      `json:"column"`
+	Template string      `json:"template"`
+	Expr     string      `json:"expr"`
+	Value    interface{} `json:"value"`
+	Format   string      `json:"format"`
+	Unit     string      `json:"unit"`
+	expr     exprNode
+	scale    float64
+}
+
+type row struct {
+	rec     import_util.CsvRecord
+	mapping *mapping
+}
+
+var nutrientUnits = map[string]string{
+	"Calories":           "kcal",
+	"Protein":            "g",
+	"Carbohydrate":       "g",
+	"Fat":                "g",
+	"Fiber":              "g",
+	"Sugar":              "g",
+	"SaturatedFat":       "g",
+	"MonounsaturatedFat": "g",
+	"PolyunsaturatedFat": "g",
+	"TransFat":           "g",
+	"Cholesterol":        "mg",
+	"Sodium":             "mg",
+	"Potassium":          "mg",
+	"Calcium":            "%",
+	"Iron":               "%",
+	"VitaminC":           "%",
+	"VitaminA":           "%",
+}
+
+var massUnitsMg = map[string]float64{"g": 1000, "mg": 1, "mcg": 0.001, "µg": 0.001, "ug": 0.001, "kg": 1e6, "oz": 28349.523125}
+
+var dailyValuesMg = map[string]float64{
+	"Calcium":  fatsecret.DailyValueCalciumMg,
+	"Iron":     fatsecret.DailyValueIronMg,
+	"VitaminC": fatsecret.DailyValueVitaminCMg,
+}
+
+var strftimeLayouts = strings.NewReplacer(
+	"%Y", "2006", "%y", "06", "%m", "01", "%d", "02", "%e", "_2", "%b", "Jan", "%B", "January",
+	"%H", "15", "%I", "03", "%M", "04", "%S", "05", "%p", "PM", "%z", "-0700", "%%", "%",
+)
+
+var templatePlaceholder = regexp.MustCompile(`\{([^}]+)}`)
+
+func ReadFile(inputPath string, opts Options) (*fatsecret.DiaryData, error) {
+	m, err := readMapping(opts.MappingPath)
+	if err != nil {
+		return nil, fmt.Errorf("CSV: %v", err)
+	}
+	delimiter, err := getDelimiter(m.Delimiter, inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("CSV: %v", err)
+	}
+
+	fp, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("CSV: error when opening %s: %v", inputPath, err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	builder := import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate)
+	entryIds := map[string]int{}
+	err = import_util.ReadCsv(fp, delimiter, func(rec import_util.CsvRecord) error {
+		entry, err := m.readEntry(rec, entryIds)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", rec.Line, err)
+		}
+		if builder.InRange(entry.Date) {
+			builder.AddEntry(entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CSV: %s: %v", inputPath, err)
+	}
+
+	return builder.Build(), nil
+}
+
+func readMapping(mappingPath string) (*mapping, error) {
+	data, err := os.ReadFile(mappingPath)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading mapping: %v", err)
+	}
+
+	m := &mapping{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(m); err != nil {
+		return nil, fmt.Errorf("error when parsing mapping %s: %v", mappingPath, err)
+	}
+	if err := m.compile(); err != nil {
+		return nil, fmt.Errorf("invalid mapping %s: %v", mappingPath, err)
+	}
+	return m, nil
+}
+
+func (m *mapping) compile() error {
+	if m.Date == nil {
+		return fmt.Errorf("date is not mapped")
+	}
+
+	m.computed = map[string]exprNode{}
+	for name, src := range m.Computed {
+		node, err := parseExpr(src)
+		if err != nil {
+			return fmt.Errorf("computed %s: %v", name, err)
+		}
+		m.computed[name] = node
+	}
+	for name := range m.computed {
+		if err := m.checkCycles(name, map[string]bool{}); err != nil {
+			return err
+		}
+	}
+
+	fields := map[string]*fieldMapping{
+		"id": m.Id, "date": m.Date, "meal": m.Meal, "name": m.Name, "description": m.Description, "quantity": m.Quantity,
+	}
+	for name, field := range m.Nutrients {
+		unit, ok := nutrientUnits[name]
+		if !ok {
+			return fmt.Errorf("unknown nutrient %s, known nutrients: %s", name, strings.Join(fatsecret.NutrientNames, ", "))
+		}
+		if field == nil {
+			return fmt.Errorf("nutrients.%s is empty", name)
+		}
+		scale, err := getUnitScale(name, unit, field.Unit)
+		if err != nil {
+			return err
+		}
+		field.scale = scale
+		fields["nutrients."+name] = field
+	}
+	for name, field := range m.Other {
+		if field == nil {
+			return fmt.Errorf("other.%s is empty", name)
+		}
+		field.scale = 1
+		fields["other."+name] = field
+	}
+
+	for name, field := range fields {
+		if field == nil {
+			continue
+		}
+		sources := 0
+		for _, isSet := range []bool{field.Column != "", field.Template != "", field.Expr != "", field.Value != nil} {
+			if isSet {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("%s should have exactly one of column, template, expr or value", name)
+		}
+		if field.Expr != "" {
+			node, err := parseExpr(field.Expr)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			field.expr = node
+		}
+	}
+	return nil
+}
+
+func (m *mapping) checkCycles(name string, visiting map[string]bool) error {
+	node, ok := m.computed[name]
+	if !ok {
+		return nil
+	}
+	if visiting[name] {
+		return fmt.Errorf("computed %s has a reference cycle", name)
+	}
+	visiting[name] = true
+	for _, ref := range node.refs() {
+		if err := m.checkCycles(ref, visiting); err != nil {
+			return err
+		}
+	}
+	delete(visiting, name)
+	return nil
+}
+
+func (m *mapping) readEntry(rec import_util.CsvRecord, entryIds map[string]int) (fatsecret.FoodEntryData, error) {
+	r := row{rec: rec, mapping: m}
+	entry := fatsecret.FoodEntryData{NumberOfUnits: 1, FoodEntryName: "Food"}
+
+	dateVal, err := r.text(m.Date)
+	if err != nil {
+		return entry, err
+	}
+	if entry.Date, err = parseDate(dateVal, m.Date.Format); err != nil {
+		return entry, err
+	}
+
+	meal, err := r.text(m.Meal)
+	if err != nil {
+		return entry, err
+	}
+	entry.Meal = import_util.NormalizeMeal(meal)
+	if name, err := r.text(m.Name); err != nil {
+		return entry, err
+	} else if name != "" {
+		entry.FoodEntryName = name
+	}
+	if entry.FoodEntryDescription, err = r.text(m.Description); err != nil {
+		return entry, err
+	}
+	if quantity, ok, err := r.number(m.Quantity); err != nil {
+		return entry, err
+	} else if ok {
+		entry.NumberOfUnits = quantity
+	}
+
+	for _, name := range fatsecret.NutrientNames {
+		val, ok, err := r.number(m.Nutrients[name])
+		if err != nil {
+			return entry, fmt.Errorf("%s: %v", name, err)
+		}
+		if !ok {
+			entry.MissingNutrients = append(entry.MissingNutrients, name)
+			continue
+		}
+		*entry.NutrientField(name) = val
+	}
+	for name, field := range m.Other {
+		val, ok, err := r.number(field)
+		if err != nil {
+			return entry, fmt.Errorf("%s: %v", name, err)
+		}
+		if ok {
+			if entry.OtherNutrients == nil {
+				entry.OtherNutrients = map[string]float64{}
+			}
+			entry.OtherNutrients[name] = val
+		}
+	}
+
+	entry.FoodId = import_util.SyntheticId(source, "food", entry.FoodEntryName)
+	if id, err := r.text(m.Id); err != nil {
+		return entry, err
+	} else if id != "" {
+		entry.FoodEntryId = import_util.SyntheticId(source, "entry", id)
+	} else {
+		entryKey := strings.Join([]string{
+			dateVal, meal, entry.FoodEntryName, strconv.FormatFloat(entry.NumberOfUnits, 'f', -1, 64),
+		}, "\x00")
+		entry.FoodEntryId = import_util.SyntheticId(source, "entry", entryKey, strconv.Itoa(entryIds[entryKey]))
+		entryIds[entryKey]++
+	}
+	return entry, nil
+}
+
+func (r row) text(field *fieldMapping) (string, error) {
+	switch {
+	case field == nil:
+		return "", nil
+	case field.Value != nil:
+		return strings.TrimSpace(fmt.Sprint(field.Value)), nil
+	case field.Template != "":
+		var err error
+		res := templatePlaceholder.ReplaceAllStringFunc(field.Template, func(placeholder string) string {
+			val, placeholderErr := r.column(strings.TrimSpace(placeholder[1 : len(placeholder)-1]))
+			if placeholderErr != nil && err == nil {
+				err = placeholderErr
+			}
+			return val
+		})
+		return strings.Join(strings.Fields(res), " "), err
+	case field.expr != nil:
+		val, ok, err := field.expr.eval(r.lookup)
+		if err != nil || !ok {
+			return "", err
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	default:
+		return r.column(field.Column)
+	}
+}
+
+func (r row) number(field *fieldMapping) (float64, bool, error) {
+	var val float64
+	var ok bool
+	var err error
+	switch {
+	case field == nil:
+		return 0, false, nil
+	case field.Value != nil:
+		switch constVal := field.Value.(type) {
+		case float64:
+			val, ok = constVal, true
+		case string:
+			val, err = strconv.ParseFloat(strings.TrimSpace(constVal), 64)
+			ok = err == nil
+		default:
+			err = fmt.Errorf("value %v is not a number", field.Value)
+		}
+	case field.Template != "":
+		return 0, false, fmt.Errorf("template can't be used for a number")
+	case field.expr != nil:
+		val, ok, err = field.expr.eval(r.lookup)
+	default:
+		val, ok, err = r.lookup(field.Column)
+	}
+
+	if field.scale != 0 {
+		val *= field.scale
+	}
+	return val, ok, err
+}
+
+func (r row) column(name string) (string, error) {
+	if node, ok := r.mapping.computed[name]; ok {
+		val, ok, err := node.eval(r.lookup)
+		if err != nil || !ok {
+			return "", err
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	}
+	if !r.rec.Has(name) {
+		return "", fmt.Errorf("unknown column %s", name)
+	}
+	return r.rec.Get(name), nil
+}
+
+func (r row) lookup(name string) (float64, bool, error) {
+	if node, ok := r.mapping.computed[name]; ok {
+		return node.eval(r.lookup)
+	}
+	if !r.rec.Has(name) {
+		return 0, false, fmt.Errorf("unknown column %s", name)
+	}
+	return r.rec.Float(name)
+}
+
+func parseDate(val string, format string) (time.Time, error) {
+	if format == "" {
+		return import_util.ParseDate(val)
+	}
+	if strings.Contains(format, "%") {
+		format = strftimeLayouts.Replace(format)
+	}
+	return import_util.ParseDate(val, format)
+}
+
+func getUnitScale(nutrient string, targetUnit string, unit string) (float64, error) {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if unit == "" || unit == strings.ToLower(targetUnit) {
+		return 1, nil
+	}
+
+	switch targetUnit {
+	case "kcal":
+		switch unit {
+		case "cal":
+			return 1, nil
+		case "kj":
+			return 1 / 4.184, nil
+		}
+	case "g", "mg":
+		if from, ok := massUnitsMg[unit]; ok {
+			return from / massUnitsMg[targetUnit], nil
+		}
+	case "%":
+		if from, ok := massUnitsMg[unit]; ok && dailyValuesMg[nutrient] != 0 {
+			return from * 100 / dailyValuesMg[nutrient], nil
+		}
+	}
+	return 0, fmt.Errorf("%s: can't convert %s to %s", nutrient, unit, targetUnit)
+}
+
+func getDelimiter(delimiter string, inputPath string) (rune, error) {
+	switch strings.ToLower(delimiter) {
+	case "":
+		switch strings.ToLower(filepath.Ext(inputPath)) {
+		case ".tsv", ".tab":
+			return '\t', nil
+		default:
+			return ',', nil
+		}
+	case "\\t", "\t", "tab":
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return 0, fmt.Errorf("delimiter should be a single character, not %s", delimiter)
+	}
+	res, _ := utf8.DecodeRuneInString(delimiter)
+	return res, nil
+}
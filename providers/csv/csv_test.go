@@ -0,0 +1,141 @@
+package csv
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func compileTestMapping(src string) (*mapping, error) {
+	m := &mapping{}
+	if err := json.Unmarshal([]byte(src), m); err != nil {
+		return nil, err
+	}
+	return m, m.compile()
+}
+
+func TestMappingCompileErrors(t *testing.T) {
+	cases := map[string]string{
+		`{}`: "date is not mapped",
+		`{"date": {"column": "Date"}, "nutrients": {"Protein": null}}`:                     "nutrients.Protein is empty",
+		`{"date": {"column": "Date"}, "other": {"x": null}}`:                               "other.x is empty",
+		`{"date": {"column": "Date"}, "nutrients": {"Protein": {}}}`:                       "nutrients.Protein should have exactly one of",
+		`{"date": {"column": "Date", "value": "2024-03-05"}}`:                              "date should have exactly one of",
+		`{"date": {"column": "Date"}, "nutrients": {"Vitamin": {"column": "V"}}}`:          "unknown nutrient Vitamin",
+		`{"date": {"column": "Date"}, "nutrients": {"Fat": {"column": "F", "unit": "l"}}}`: "Fat: can't convert l to g",
+		`{"date": {"column": "Date"}, "computed": {"a": "b + 1", "b": "a * 2"}}`:           "has a reference cycle",
+		`{"date": {"column": "Date"}, "computed": {"a": "a + 1"}}`:                         "computed a has a reference cycle",
+		`{"date": {"column": "Date"}, "computed": {"a": "(1 + "}}`:                         "computed a: invalid expression",
+		`{"date": {"column": "Date"}, "name": {"expr": "1 +"}}`:                            "name: invalid expression",
+	}
+	for src, expected := range cases {
+		_, err := compileTestMapping(src)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error with %q, got %v", src, expected, err)
+		}
+	}
+}
+
+func TestMappingCompile(t *testing.T) {
+	m, err := compileTestMapping(`{
+		"computed": {"fat": "sat + unsat", "sat": "{Saturated fat}", "unsat": "{Unsaturated fat}"},
+		"date": {"column": "Date"},
+		"nutrients": {
+			"Calories": {"column": "Energy", "unit": "kJ"},
+			"Fat": {"expr": "fat"},
+			"Calcium": {"column": "Calcium", "unit": "mg"}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Nutrients["Fat"].expr == nil {
+		t.Error("expression of Fat is not compiled")
+	}
+	if scale := m.Nutrients["Calories"].scale; scale != 1/4.184 {
+		t.Errorf("unexpected kJ scale %v", scale)
+	}
+	if scale := m.Nutrients["Calcium"].scale; scale != 0.1 {
+		t.Errorf("unexpected calcium scale %v", scale)
+	}
+}
+
+func writeTestFiles(t *testing.T, mappingSrc string, csvSrc string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	mappingPath := filepath.Join(dir, "mapping.json")
+	if err := os.WriteFile(mappingPath, []byte(mappingSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputPath := filepath.Join(dir, "diary.csv")
+	if err := os.WriteFile(inputPath, []byte(csvSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mappingPath, inputPath
+}
+
+func TestReadFile(t *testing.T) {
+	mappingPath, inputPath := writeTestFiles(t, `{
+		"computed": {"fat": "{Saturated fat} + {Unsaturated fat}"},
+		"date": {"column": "Date", "format": "%d.%m.%Y"},
+		"meal": {"column": "Meal"},
+		"name": {"template": "{Food} {Brand}"},
+		"quantity": {"value": 2},
+		"nutrients": {
+			"Calories": {"expr": "{Energy kJ} / 4.184"},
+			"Fat": {"expr": "fat"},
+			"Protein": {"expr": "-{Protein loss} * -1"}
+		},
+		"other": {"Water": {"column": "Water"}}
+	}`, "Date,Meal,Food,Brand,Energy kJ,Saturated fat,Unsaturated fat,Protein loss,Water\n"+
+		"05.03.2024,lunch,Soup,Acme,836.8,1.5,\"2,5\",3,\n")
+
+	data, err := ReadFile(inputPath, Options{MappingPath: mappingPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.DiaryData) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(data.DiaryData))
+	}
+
+	entry := data.DiaryData[0]
+	if !entry.Date.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date %v", entry.Date)
+	}
+	if entry.Meal != "Lunch" || entry.FoodEntryName != "Soup Acme" || entry.NumberOfUnits != 2 {
+		t.Errorf("unexpected entry %s %s %v", entry.Meal, entry.FoodEntryName, entry.NumberOfUnits)
+	}
+	if entry.Calories < 199.99 || entry.Calories > 200.01 {
+		t.Errorf("unexpected calories %v", entry.Calories)
+	}
+	if entry.Fat != 4 || entry.Protein != 3 {
+		t.Errorf("unexpected fat %v or protein %v", entry.Fat, entry.Protein)
+	}
+	if entry.IsNutrientMissing("Fat") || !entry.IsNutrientMissing("Sugar") {
+		t.Errorf("unexpected missing nutrients %v", entry.MissingNutrients)
+	}
+	if _, ok := entry.OtherNutrients["Water"]; ok {
+		t.Errorf("empty Water cell is imported: %v", entry.OtherNutrients)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	cases := []struct {
+		mapping  string
+		expected string
+	}{
+		{`{"date": {"column": "Date"}, "nutrients": {"Fat": {"expr": "Fat / Grams"}}}`, "line 2: Fat: division by zero"},
+		{`{"date": {"column": "Date"}, "nutrients": {"Fat": {"expr": "{No such column}"}}}`, "line 2: Fat: unknown column No such column"},
+		{`{"date": {"column": "Date"}, "nutrients": {"Fat": {"column": "Name"}}}`, "line 2: Fat:"},
+	}
+	for _, item := range cases {
+		mappingPath, inputPath := writeTestFiles(t, item.mapping, "Date,Name,Fat,Grams\n2024-03-05,Soup,3,0\n")
+		_, err := ReadFile(inputPath, Options{MappingPath: mappingPath})
+		if err == nil || !strings.Contains(err.Error(), item.expected) {
+			t.Errorf("%s: expected error with %q, got %v", item.mapping, item.expected, err)
+		}
+	}
+}
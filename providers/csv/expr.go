@@ -0,0 +1,209 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type exprEnv func(name string) (float64, bool, error)
+
+type exprNode interface {
+	eval(env exprEnv) (float64, bool, error)
+	refs() []string
+}
+
+type exprNumber float64
+
+type exprRef string
+
+type exprNeg struct {
+	x exprNode
+}
+
+type exprBinary struct {
+	op    byte
+	left  exprNode
+	right exprNode
+}
+
+func (n exprNumber) eval(exprEnv) (float64, bool, error) {
+	return float64(n), true, nil
+}
+
+func (n exprNumber) refs() []string {
+	return nil
+}
+
+func (n exprRef) eval(env exprEnv) (float64, bool, error) {
+	return env(string(n))
+}
+
+func (n exprRef) refs() []string {
+	return []string{string(n)}
+}
+
+func (n exprNeg) eval(env exprEnv) (float64, bool, error) {
+	val, ok, err := n.x.eval(env)
+	return -val, ok, err
+}
+
+func (n exprNeg) refs() []string {
+	return n.x.refs()
+}
+
+func (n exprBinary) eval(env exprEnv) (float64, bool, error) {
+	left, ok, err := n.left.eval(env)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	right, ok, err := n.right.eval(env)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, true, nil
+	case '-':
+		return left - right, true, nil
+	case '*':
+		return left * right, true, nil
+	default:
+		if right == 0 {
+			return 0, false, fmt.Errorf("division by zero")
+		}
+		return left / right, true, nil
+	}
+}
+
+func (n exprBinary) refs() []string {
+	return append(n.left.refs(), n.right.refs()...)
+}
+
+type exprParser struct {
+	src []rune
+	pos int
+}
+
+// Expressions are arithmetic over numbers and columns, e.g. `{Energy, kJ} / 4.184` or `fat_sat + fat_unsat`
+func parseExpr(src string) (exprNode, error) {
+	p := &exprParser{src: []rune(src)}
+	res, err := p.parseSum()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", src, err)
+	}
+	if p.skipSpaces(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q at %d", src, p.src[p.pos], p.pos)
+	}
+	return res, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp("+-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp("*/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseFactor() (exprNode, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '-':
+		p.pos++
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return exprNeg{x: x}, nil
+	case c == '(':
+		p.pos++
+		res, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpaces(); p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		p.pos++
+		return res, nil
+	case c == '{':
+		end := p.pos + 1
+		for end < len(p.src) && p.src[end] != '}' {
+			end++
+		}
+		if end >= len(p.src) {
+			return nil, fmt.Errorf("missing } at %d", p.pos)
+		}
+		name := strings.TrimSpace(string(p.src[p.pos+1 : end]))
+		p.pos = end + 1
+		return exprRef(name), nil
+	case unicode.IsDigit(c) || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		val, err := strconv.ParseFloat(string(p.src[start:p.pos]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return exprNumber(val), nil
+	case unicode.IsLetter(c) || c == '_':
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsLetter(p.src[p.pos]) || unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '_') {
+			p.pos++
+		}
+		return exprRef(string(p.src[start:p.pos])), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", c, p.pos)
+	}
+}
+
+func (p *exprParser) acceptOp(ops string) (byte, bool) {
+	p.skipSpaces()
+	if p.pos < len(p.src) && strings.ContainsRune(ops, p.src[p.pos]) {
+		p.pos++
+		return byte(p.src[p.pos-1]), true
+	}
+	return 0, false
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
@@ -0,0 +1,102 @@
+package csv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func getTestEnv(values map[string]float64) exprEnv {
+	return func(name string) (float64, bool, error) {
+		val, ok := values[name]
+		if !ok {
+			return 0, false, fmt.Errorf("unknown column %s", name)
+		}
+		return val, true, nil
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	env := getTestEnv(map[string]float64{"a": 2, "b": 3, "fat_sat": 1.5, "Energy, kJ": 418.4, "Total fat": 10})
+	cases := []struct {
+		src      string
+		expected float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"a * b + 1", 7},
+		{"-a + b", 1},
+		{"-(a + b) * 2", -10},
+		{"a * -b", -6},
+		{"--a", 2},
+		{"{Energy, kJ} / 4 * 10", 1046},
+		{"{ Total fat } - fat_sat", 8.5},
+		{".5 * a", 1},
+	}
+	for _, item := range cases {
+		node, err := parseExpr(item.src)
+		if err != nil {
+			t.Errorf("%s: %v", item.src, err)
+			continue
+		}
+		val, ok, err := node.eval(env)
+		if err != nil || !ok {
+			t.Errorf("%s: unexpected result %v, %v", item.src, ok, err)
+			continue
+		}
+		if val != item.expected {
+			t.Errorf("%s: expected %v, got %v", item.src, item.expected, val)
+		}
+	}
+}
+
+func TestParseExprRefs(t *testing.T) {
+	node, err := parseExpr("{Energy, kJ} / 4.184 + -fat_sat * (a - 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := node.refs()
+	sort.Strings(refs)
+	if expected := []string{"Energy, kJ", "a", "fat_sat"}; !reflect.DeepEqual(refs, expected) {
+		t.Errorf("expected refs %v, got %v", expected, refs)
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	cases := map[string]string{
+		"":          "unexpected end",
+		"1 +":       "unexpected end",
+		"(1 + 2":    "missing )",
+		"{Energy":   "missing }",
+		"1 2":       "unexpected '2'",
+		"a % b":     "unexpected '%'",
+		"1.2.3 + a": "invalid syntax",
+	}
+	for src, expected := range cases {
+		_, err := parseExpr(src)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected error with %q, got %v", src, expected, err)
+		}
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	node, err := parseExpr("a / (b - 3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := node.eval(getTestEnv(map[string]float64{"a": 1, "b": 3})); err == nil || err.Error() != "division by zero" {
+		t.Errorf("expected division by zero, got %v", err)
+	}
+
+	missing := func(name string) (float64, bool, error) {
+		return 0, name != "b", nil
+	}
+	if _, ok, err := node.eval(missing); ok || err != nil {
+		t.Errorf("expected missing value, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,95 @@
+package fatsecret
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const clockOffsetFileName = "clock_offset.json"
+const minClockOffsetCorrection = time.Second * 2
+
+type clockOffsetData struct {
+	OffsetSeconds int64  `json:"offset_seconds"`
+	Time          uint64 `json:"time"`
+}
+
+func isTimestampRejected(resp *http.Response, respBody []byte) bool {
+	bodyData := struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(respBody, &bodyData); err == nil {
+		return bodyData.Error.Code == ErrCodeInvalidTimestamp
+	}
+
+	return resp.StatusCode > 201 && strings.Contains(strings.ToLower(string(respBody)), "timestamp")
+}
+
+func (s *FatSOauth1Service) correctClockOffset(resp *http.Response) (bool, error) {
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		log.Printf("WARN: FatSecret: OAuth timestamp was rejected and there is no valid Date header: %v", err)
+		return false, nil
+	}
+
+	offset := serverTime.Sub(time.Now()).Round(time.Second)
+	delta := offset - s.clockOffset
+	if delta < minClockOffsetCorrection && delta > -minClockOffsetCorrection {
+		log.Printf("WARN: FatSecret: OAuth timestamp was rejected but clock offset %s is unchanged", s.clockOffset.String())
+		return false, nil
+	}
+
+	log.Printf("WARN: FatSecret: OAuth timestamp was rejected, correcting clock offset to %s", offset.String())
+	s.clockOffset = offset
+	if err := s.saveClockOffset(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FatSOauth1Service) loadClockOffset() error {
+	filePath, err := s.storage.GetFile(clockOffsetFileName, 0644)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading FatSOauth1Service clock offset file: %v", err)
+	}
+
+	res := clockOffsetData{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil
+	}
+	s.clockOffset = time.Duration(res.OffsetSeconds) * time.Second
+	return nil
+}
+
+func (s *FatSOauth1Service) saveClockOffset() error {
+	filePath, err := s.storage.GetFile(clockOffsetFileName, 0644)
+	if err != nil {
+		return err
+	}
+
+	data := clockOffsetData{
+		OffsetSeconds: int64(s.clockOffset / time.Second),
+		Time:          uint64(time.Now().Unix()),
+	}
+
+	var res []byte
+	if res, err = json.Marshal(data); err != nil {
+		return fmt.Errorf("error when serializing clock offset data: %v", err)
+	}
+
+	if err := os.WriteFile(filePath, res, 0644); err != nil {
+		return fmt.Errorf("error when writing clock offset data: %v", err)
+	}
+	return nil
+}
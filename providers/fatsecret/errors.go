@@ -0,0 +1,49 @@
+package fatsecret
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrAuthRequired = errors.New("FatSecret: authorization required")
+var ErrRateLimited = errors.New("FatSecret: rate limited")
+
+const (
+	ErrCodeUnknown                   = 1
+	ErrCodeMissingOauthParameter     = 2
+	ErrCodeUnsupportedOauthParameter = 3
+	ErrCodeInvalidSignatureMethod    = 4
+	ErrCodeInvalidConsumerKey        = 5
+	ErrCodeInvalidTimestamp          = 6
+	ErrCodeInvalidNonce              = 7
+	ErrCodeInvalidSignature          = 8
+	ErrCodeInvalidAccessToken        = 9
+	ErrCodeRateLimited               = 12
+	ErrCodeInvalidOrExpiredToken     = 13
+	ErrCodeMissingParameter          = 101
+	ErrCodeInvalidType               = 102
+	ErrCodeInvalidDate               = 103
+	ErrCodeInvalidId                 = 106
+	ErrCodeValueOutOfRange           = 107
+	ErrCodeInvalidValue              = 108
+)
+
+type APIError struct {
+	Code    int
+	Method  string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("FatSecret: API error: method=%s, code=%d: %s", e.Method, e.Code, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAuthRequired:
+		return e.Code == ErrCodeInvalidAccessToken || e.Code == ErrCodeInvalidOrExpiredToken
+	case ErrRateLimited:
+		return e.Code == ErrCodeRateLimited
+	}
+	return false
+}
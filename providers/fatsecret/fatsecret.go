@@ -8,7 +8,6 @@ import (
 	"math"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -28,14 +27,9 @@ func New(keyData []byte, options ...func(s *FatSecret)) (*FatSecret, error) {
 	if err := json.Unmarshal(keyData, &keys); err != nil {
 		return nil, fmt.Errorf("error when parsing FatSecret keys: %v", err)
 	}
-	oauth := NewFatSOauth1Service(keys)
-	err := oauth.GetRequestToken()
+	oauth, err := NewFatSOauth1Service(keys)
 	if err != nil {
-		log.Fatalf("FatSecret: %v\n", err)
-	}
-	err = oauth.GetAuthCode()
-	if err != nil {
-		log.Fatalf("FatSecret: %v\n", err)
+		return nil, fmt.Errorf("FatSecret: %w", err)
 	}
 
 	p := &FatSecret{oauth: oauth}
@@ -45,6 +39,12 @@ func New(keyData []byte, options ...func(s *FatSecret)) (*FatSecret, error) {
 	return p, nil
 }
 
+func WithAuthCodePrompt(prompt func(authUrl string) (string, error)) func(s *FatSecret) {
+	return func(s *FatSecret) {
+		s.oauth.AuthCodePrompt = prompt
+	}
+}
+
 type ApiRequestRetryConfig struct {
 	Retries     int
 	Backoff     time.Duration
@@ -67,9 +67,12 @@ func (s *FatSecret) makeApiRequest(method string, reqData map[string]string, ret
 	}
 	resp, respBody, err := s.oauth.MakeHttpRequest("POST", apiUrl, reqBodyParams)
 	if err != nil {
-		return nil, fmt.Errorf("FatSecret: error when making request for method %s: %v", method, err)
+		return nil, fmt.Errorf("FatSecret: error when making request for method %s: %w", method, err)
 	}
 
+	if resp.StatusCode == 429 {
+		return nil, fmt.Errorf("%w: HTTP %d: method=%s, %v", ErrRateLimited, resp.StatusCode, method, string(respBody))
+	}
 	if resp.StatusCode > 201 {
 		return nil, fmt.Errorf("FatSecret: error when making API request, HTTP %d: method=%s, %v", resp.StatusCode, method, string(respBody))
 	}
@@ -80,30 +83,44 @@ func (s *FatSecret) makeApiRequest(method string, reqData map[string]string, ret
 	}
 
 	if bodyData["error"] != nil {
-		apiErr := bodyData["error"].(map[string]interface{})
-		errCode := uint64(apiErr["code"].(float64))
-		errMsg := apiErr["message"].(string)
+		apiErr := apiErrorFromBody(method, bodyData["error"])
 
-		if retryConfig.Retries > 0 && retryConfig.RetryNumber < retryConfig.Retries && isRetryableApiError(errMsg) {
+		if retryConfig.Retries > 0 && retryConfig.RetryNumber < retryConfig.Retries && errors.Is(apiErr, ErrRateLimited) {
 			waitTime := retryConfig.Backoff * time.Duration(math.Pow(2, float64(retryConfig.RetryNumber)))
 			retryConfig.RetryNumber++
-			log.Printf("WARN: FatSecret: Retriable API error: %s", errMsg)
+			log.Printf("WARN: FatSecret: Retriable API error: %s", apiErr.Message)
 			log.Printf("WARN: FatSecret: Retry API request because of error, retryNumber=%d, waitTime=%s", retryConfig.RetryNumber, waitTime.String())
 			time.Sleep(waitTime)
 			return s.makeApiRequest(method, reqData, retryConfig)
 		}
 
-		return nil, fmt.Errorf("FatSecret: API error: method=%s, code=%d: %s", method, errCode, errMsg)
+		if errors.Is(apiErr, ErrAuthRequired) {
+			if err := s.oauth.ResetAuth(); err != nil {
+				log.Printf("WARN: FatSecret: error when resetting auth data: %v", err)
+			}
+		}
+
+		return nil, apiErr
 	}
 
 	return bodyData, nil
 }
 
-func isRetryableApiError(msg string) bool {
-	if strings.Contains(msg, "User is performing too many actions") {
-		return true
+func apiErrorFromBody(method string, rawErr interface{}) *APIError {
+	res := &APIError{Code: ErrCodeUnknown, Method: method}
+
+	errData, ok := rawErr.(map[string]interface{})
+	if !ok {
+		res.Message = fmt.Sprintf("%v", rawErr)
+		return res
 	}
-	return false
+	if code, ok := errData["code"].(float64); ok {
+		res.Code = int(code)
+	}
+	if msg, ok := errData["message"].(string); ok {
+		res.Message = msg
+	}
+	return res
 }
 
 type FoodEntriesDataRaw struct {
@@ -176,6 +193,12 @@ type FoodEntryData struct {
 	VitaminC             float64
 	Sodium               float64
 	Potassium            float64
+	MissingNutrients     []string           `json:",omitempty"`
+	OtherNutrients       map[string]float64 `json:",omitempty"`
+	Barcode              string             `json:",omitempty"`
+	NovaGroup            int                `json:",omitempty"`
+	Ingredients          string             `json:",omitempty"`
+	FdcId                int64              `json:",omitempty"`
 }
 
 func FoodEntriesDataFromRaw(rawData FoodEntriesDataRaw) (*FoodEntriesData, error) {
@@ -311,22 +334,115 @@ func FoodEntriesDataFromRaw(rawData FoodEntriesDataRaw) (*FoodEntriesData, error
 			VitaminC:             vitaminC,
 			Sodium:               sodium,
 			Potassium:            potassium,
+			MissingNutrients:     getMissingNutrients(item),
 		})
 	}
 
 	return &res, nil
 }
 
+func getMissingNutrients(item FoodEntryDataRaw) []string {
+	rawNutrients := []struct {
+		Name  string
+		Value string
+	}{
+		{"Protein", item.Protein},
+		{"Calories", item.Calories},
+		{"Carbohydrate", item.Carbohydrate},
+		{"Fat", item.Fat},
+		{"Fiber", item.Fiber},
+		{"Sugar", item.Sugar},
+		{"Calcium", item.Calcium},
+		{"Cholesterol", item.Cholesterol},
+		{"Iron", item.Iron},
+		{"MonounsaturatedFat", item.MonounsaturatedFat},
+		{"PolyunsaturatedFat", item.PolyunsaturatedFat},
+		{"SaturatedFat", item.SaturatedFat},
+		{"TransFat", item.TransFat},
+		{"VitaminA", item.VitaminA},
+		{"VitaminC", item.VitaminC},
+		{"Sodium", item.Sodium},
+		{"Potassium", item.Potassium},
+	}
+
+	var res []string
+	for _, nutrient := range rawNutrients {
+		if nutrient.Value == "" {
+			res = append(res, nutrient.Name)
+		}
+	}
+	return res
+}
+
+const DailyValueCalciumMg = 1000
+const DailyValueIronMg = 18
+const DailyValueVitaminCMg = 60
+
+var NutrientNames = []string{
+	"Protein", "Calories", "Carbohydrate", "Fat", "Fiber", "Sugar", "Calcium", "Cholesterol", "Iron",
+	"MonounsaturatedFat", "PolyunsaturatedFat", "SaturatedFat", "TransFat", "VitaminA", "VitaminC", "Sodium", "Potassium",
+}
+
+func (e *FoodEntryData) NutrientField(name string) *float64 {
+	switch name {
+	case "Protein":
+		return &e.Protein
+	case "Calories":
+		return &e.Calories
+	case "Carbohydrate":
+		return &e.Carbohydrate
+	case "Fat":
+		return &e.Fat
+	case "Fiber":
+		return &e.Fiber
+	case "Sugar":
+		return &e.Sugar
+	case "Calcium":
+		return &e.Calcium
+	case "Cholesterol":
+		return &e.Cholesterol
+	case "Iron":
+		return &e.Iron
+	case "MonounsaturatedFat":
+		return &e.MonounsaturatedFat
+	case "PolyunsaturatedFat":
+		return &e.PolyunsaturatedFat
+	case "SaturatedFat":
+		return &e.SaturatedFat
+	case "TransFat":
+		return &e.TransFat
+	case "VitaminA":
+		return &e.VitaminA
+	case "VitaminC":
+		return &e.VitaminC
+	case "Sodium":
+		return &e.Sodium
+	case "Potassium":
+		return &e.Potassium
+	default:
+		return nil
+	}
+}
+
+func (e *FoodEntryData) IsNutrientMissing(name string) bool {
+	for _, missingName := range e.MissingNutrients {
+		if missingName == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *FatSecret) FoodEntriesGet(date time.Time) (*FoodEntriesData, error) {
 	if err := s.oauth.Authorize(); err != nil {
-		return nil, fmt.Errorf("FatSecret: auth error: %v", err)
+		return nil, fmt.Errorf("FatSecret: auth error: %w", err)
 	}
 
 	days := misc.DateToDaysFromEpoch(date)
 	reqData := map[string]string{"date": strconv.FormatInt(days, 10)}
 	rawData, err := s.makeApiRequest("food_entries.get.v2", reqData, nil)
 	if err != nil {
-		return nil, fmt.Errorf("FatSecret: error when requesting food entries: %v", err)
+		return nil, fmt.Errorf("FatSecret: error when requesting food entries: %w", err)
 	}
 
 	rawRes := FoodEntriesDataRaw{}
@@ -441,14 +557,14 @@ func FoodEntriesMonthDataFromRaw(rawData *FoodEntriesMonthDataRaw) (*FoodEntries
 
 func (s *FatSecret) FoodEntriesGetMonth(fromDate time.Time) (*FoodEntriesMonthData, error) {
 	if err := s.oauth.Authorize(); err != nil {
-		return nil, fmt.Errorf("FatSecret: auth error: %v", err)
+		return nil, fmt.Errorf("FatSecret: auth error: %w", err)
 	}
 
 	days := misc.DateToDaysFromEpoch(fromDate)
 	reqData := map[string]string{"date": strconv.FormatInt(days, 10)}
 	rawData, err := s.makeApiRequest("food_entries.get_month.v2", reqData, nil)
 	if err != nil {
-		return nil, fmt.Errorf("FatSecret: error when requesting food entries for month: %v", err)
+		return nil, fmt.Errorf("FatSecret: error when requesting food entries for month: %w", err)
 	}
 
 	rawRes := FoodEntriesMonthDataRaw{}
@@ -464,28 +580,162 @@ type DiaryData struct {
 	ToDate            time.Time
 	AggregatedDayData []FoodEntryDayData
 	DiaryData         []FoodEntryData
+	Weights           []WeightData   `json:",omitempty"`
+	Exercises         []ExerciseData `json:",omitempty"`
+}
+
+type WeightData struct {
+	DateInt  int64
+	Date     time.Time
+	WeightKg float64
+	Comment  string `json:",omitempty"`
+}
+
+type ExerciseData struct {
+	DateInt         int64
+	Date            time.Time
+	Name            string
+	DurationMinutes float64
+	Calories        float64
+	Steps           int64   `json:",omitempty"`
+	DistanceKm      float64 `json:",omitempty"`
+}
+
+type DiaryConsumer interface {
+	ConsumeDay(day FoodEntryDayData) error
+	ConsumeEntry(entry FoodEntryData) error
+}
+
+type WeightConsumer interface {
+	ConsumeWeight(weight WeightData) error
+}
+
+type ExerciseConsumer interface {
+	ConsumeExercise(exercise ExerciseData) error
+}
+
+type DiaryConsumers []DiaryConsumer
+
+func (c DiaryConsumers) ConsumeDay(day FoodEntryDayData) error {
+	for _, consumer := range c {
+		if err := consumer.ConsumeDay(day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c DiaryConsumers) ConsumeEntry(entry FoodEntryData) error {
+	for _, consumer := range c {
+		if err := consumer.ConsumeEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c DiaryConsumers) ConsumeWeight(weight WeightData) error {
+	for _, consumer := range c {
+		if weightConsumer, ok := consumer.(WeightConsumer); ok {
+			if err := weightConsumer.ConsumeWeight(weight); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c DiaryConsumers) ConsumeExercise(exercise ExerciseData) error {
+	for _, consumer := range c {
+		if exerciseConsumer, ok := consumer.(ExerciseConsumer); ok {
+			if err := exerciseConsumer.ConsumeExercise(exercise); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DiaryData) ConsumeDay(day FoodEntryDayData) error {
+	if len(d.AggregatedDayData) == 0 {
+		d.FromDate = day.Date
+	}
+	d.ToDate = day.Date
+	d.AggregatedDayData = append(d.AggregatedDayData, day)
+	return nil
+}
+
+func (d *DiaryData) ConsumeEntry(entry FoodEntryData) error {
+	d.DiaryData = append(d.DiaryData, entry)
+	return nil
+}
+
+func (d *DiaryData) ConsumeWeight(weight WeightData) error {
+	d.Weights = append(d.Weights, weight)
+	return nil
+}
+
+func (d *DiaryData) ConsumeExercise(exercise ExerciseData) error {
+	d.Exercises = append(d.Exercises, exercise)
+	return nil
+}
+
+func (d *DiaryData) Stream(consumer DiaryConsumer) error {
+	for _, day := range d.AggregatedDayData {
+		if err := consumer.ConsumeDay(day); err != nil {
+			return err
+		}
+	}
+	for _, entry := range d.DiaryData {
+		if err := consumer.ConsumeEntry(entry); err != nil {
+			return err
+		}
+	}
+	if weightConsumer, ok := consumer.(WeightConsumer); ok {
+		for _, weight := range d.Weights {
+			if err := weightConsumer.ConsumeWeight(weight); err != nil {
+				return err
+			}
+		}
+	}
+	if exerciseConsumer, ok := consumer.(ExerciseConsumer); ok {
+		for _, exercise := range d.Exercises {
+			if err := exerciseConsumer.ConsumeExercise(exercise); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (s *FatSecret) GetDiary(fromDate time.Time, toDate time.Time) (*DiaryData, error) {
+	res := DiaryData{}
+	if err := s.StreamDiary(fromDate, toDate, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (s *FatSecret) StreamDiary(fromDate time.Time, toDate time.Time, consumer DiaryConsumer) error {
 	delta := toDate.Sub(fromDate)
 	if delta < 0 {
-		return nil, errors.New("FatSecret: GetDiary: fromDate > toDate")
+		return errors.New("FatSecret: GetDiary: fromDate > toDate")
 	}
 
-	res := DiaryData{}
-
 	var dates []time.Time
 	curDate := fromDate
 	for {
 		log.Printf("FatSecret: Get diary data for month from %v\n", curDate)
 		monthData, err := s.FoodEntriesGetMonth(curDate)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		for _, item := range monthData.Month.Day {
 			dates = append(dates, item.Date)
-			res.AggregatedDayData = append(res.AggregatedDayData, item)
+			if err := consumer.ConsumeDay(item); err != nil {
+				return err
+			}
 		}
 
 		curDate = monthData.Month.ToDate.Add(time.Hour * 24)
@@ -495,23 +745,20 @@ func (s *FatSecret) GetDiary(fromDate time.Time, toDate time.Time) (*DiaryData,
 		time.Sleep(time.Second * 2)
 	}
 
-	if len(dates) > 0 {
-		res.FromDate = dates[0]
-		res.ToDate = dates[len(dates)-1]
-	}
-
 	for _, date := range dates {
 		log.Printf("FatSecret: Get diary food entries for date %v\n", date)
 		data, err := s.FoodEntriesGet(date)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		for _, foodEntry := range data.FoodEntries.FoodEntry {
-			res.DiaryData = append(res.DiaryData, foodEntry)
+			if err := consumer.ConsumeEntry(foodEntry); err != nil {
+				return err
+			}
 		}
 		time.Sleep(time.Second * 2)
 	}
 
-	return &res, nil
+	return nil
 }
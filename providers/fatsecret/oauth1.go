@@ -3,12 +3,12 @@ package fatsecret
 import (
 	"bufio"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha1"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
-	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"os"
@@ -28,10 +28,12 @@ const authorizeUrl = "https://www.fatsecret.com/oauth/authorize"
 var digitsRe, _ = regexp.Compile("^\\d+$")
 
 type FatSOauth1Service struct {
-	Keys FatSOauth1Keys
+	Keys           FatSOauth1Keys
+	AuthCodePrompt func(authUrl string) (string, error)
 
-	storage  *storage.Storage
-	authData struct {
+	storage     *storage.Storage
+	clockOffset time.Duration
+	authData    struct {
 		AuthCode           string
 		RequestToken       string
 		RequestTokenSecret string
@@ -51,24 +53,60 @@ type fatSSecretData struct {
 	Time   uint64 `json:"time"`
 }
 
-func NewFatSOauth1Service(keys FatSOauth1Keys) *FatSOauth1Service {
-	return &FatSOauth1Service{
+func NewFatSOauth1Service(keys FatSOauth1Keys) (*FatSOauth1Service, error) {
+	oauthStorage, err := storage.New("fatsecret_oauth")
+	if err != nil {
+		return nil, err
+	}
+	if err := oauthStorage.MigrateSecretFiles("fatsecret_oauth_*.json"); err != nil {
+		return nil, fmt.Errorf("error when encrypting FatSOauth1Service secret files: %v", err)
+	}
+
+	s := &FatSOauth1Service{
 		Keys:    keys,
-		storage: storage.New("fatsecret_oauth"),
+		storage: oauthStorage,
+	}
+	if err := s.loadClockOffset(); err != nil {
+		return nil, err
 	}
+	return s, nil
 }
 
 func (s *FatSOauth1Service) MakeHttpRequest(reqMethod string, reqUrl string, reqData url.Values) (*http.Response, []byte, error) {
-	reqData, err := s.addOauthParams(reqMethod, reqUrl, reqData)
+	resp, respBody, err := s.makeSignedRequest(reqMethod, reqUrl, reqData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error when creating OAuth params for request: %v", err)
+		return nil, nil, fmt.Errorf("error when making OAuth signed request: %w", err)
 	}
+	return resp, respBody, nil
+}
 
-	resp, respBody, err := req_util.MakeHttpRequest(reqMethod, reqUrl, reqData, nil)
+func (s *FatSOauth1Service) makeSignedRequest(reqMethod string, reqUrl string, reqData url.Values) (*http.Response, []byte, error) {
+	oauthParams, err := s.addOauthParams(reqMethod, reqUrl, reqData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error when making OAuth signed request: %v", err)
+		return nil, nil, fmt.Errorf("error when creating OAuth params for request: %w", err)
 	}
-	return resp, respBody, nil
+
+	resp, respBody, err := req_util.MakeHttpRequest(reqMethod, reqUrl, oauthParams, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isTimestampRejected(resp, respBody) {
+		return resp, respBody, nil
+	}
+
+	corrected, err := s.correctClockOffset(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !corrected {
+		return resp, respBody, nil
+	}
+
+	if oauthParams, err = s.addOauthParams(reqMethod, reqUrl, reqData); err != nil {
+		return nil, nil, fmt.Errorf("error when creating OAuth params for request: %w", err)
+	}
+	return req_util.MakeHttpRequest(reqMethod, reqUrl, oauthParams, nil)
 }
 
 func (s *FatSOauth1Service) Authorize() error {
@@ -77,7 +115,10 @@ func (s *FatSOauth1Service) Authorize() error {
 	}
 
 	cacheName := "access_token"
-	cachedData := s.getCachedSecret(cacheName)
+	cachedData, err := s.getCachedSecret(cacheName)
+	if err != nil {
+		return err
+	}
 	if cachedData != nil && cachedData.Value != "" && cachedData.Value2 != "" {
 		s.authData.AccessToken = cachedData.Value
 		s.authData.AccessTokenSecret = cachedData.Value2
@@ -96,14 +137,9 @@ func (s *FatSOauth1Service) Authorize() error {
 		"oauth_token":    []string{s.authData.RequestToken},
 		"oauth_verifier": []string{s.authData.AuthCode},
 	}
-	oauthParams, err := s.addOauthParams("POST", accessTokenUrl, reqData)
+	resp, respBody, err := s.makeSignedRequest("POST", accessTokenUrl, reqData)
 	if err != nil {
-		return fmt.Errorf("OAuth request token params error: %v", err)
-	}
-
-	resp, respBody, err := req_util.MakeHttpRequest("POST", accessTokenUrl, oauthParams, nil)
-	if err != nil {
-		return fmt.Errorf("OAuth request token creation error: %v", err)
+		return fmt.Errorf("OAuth request token creation error: %w", err)
 	}
 
 	if resp.StatusCode > 201 {
@@ -127,13 +163,15 @@ func (s *FatSOauth1Service) Authorize() error {
 		return fmt.Errorf("OAuth response: there is no oauth_token_secret in response")
 	}
 
-	s.setCachedSecret(cacheName, s.authData.AccessToken, s.authData.AccessTokenSecret)
-	return nil
+	return s.setCachedSecret(cacheName, s.authData.AccessToken, s.authData.AccessTokenSecret)
 }
 
 func (s *FatSOauth1Service) GetAuthCode() error {
 	cacheName := "auth_code"
-	cachedData := s.getCachedSecret(cacheName)
+	cachedData, err := s.getCachedSecret(cacheName)
+	if err != nil {
+		return err
+	}
 	if cachedData != nil && cachedData.Value != "" {
 		s.authData.AuthCode = cachedData.Value
 		return nil
@@ -143,12 +181,12 @@ func (s *FatSOauth1Service) GetAuthCode() error {
 		return err
 	}
 
-	fmt.Println("==> Go to the authorize URL and enter code")
-	fmt.Printf("Authorize URL: %s\n", authorizeUrl+"?oauth_token="+s.authData.RequestToken)
+	authUrl := authorizeUrl + "?oauth_token=" + s.authData.RequestToken
+	if s.AuthCodePrompt == nil {
+		return fmt.Errorf("%w: authorize URL: %s", ErrAuthRequired, authUrl)
+	}
 
-	fmt.Print("Enter code: ")
-	reader := bufio.NewReader(os.Stdin)
-	val, err := reader.ReadString('\n')
+	val, err := s.AuthCodePrompt(authUrl)
 	if err != nil {
 		return fmt.Errorf("error when reading authorize token: %v", err)
 	}
@@ -158,14 +196,38 @@ func (s *FatSOauth1Service) GetAuthCode() error {
 		return fmt.Errorf("invalid authorization code: %s", val)
 	}
 	s.authData.AuthCode = val
-	s.setCachedSecret(cacheName, val, "")
+	return s.setCachedSecret(cacheName, val, "")
+}
 
+func (s *FatSOauth1Service) ResetAuth() error {
+	for _, cacheName := range []string{"request_token", "auth_code", "access_token"} {
+		if err := s.setCachedSecret(cacheName, "", ""); err != nil {
+			return err
+		}
+	}
+	s.authData.AuthCode = ""
+	s.authData.RequestToken = ""
+	s.authData.RequestTokenSecret = ""
+	s.authData.AccessToken = ""
+	s.authData.AccessTokenSecret = ""
 	return nil
 }
 
+func PromptAuthCodeFromStdin(authUrl string) (string, error) {
+	fmt.Fprintln(os.Stderr, "==> Go to the authorize URL and enter code")
+	fmt.Fprintf(os.Stderr, "Authorize URL: %s\n", authUrl)
+
+	fmt.Fprint(os.Stderr, "Enter code: ")
+	reader := bufio.NewReader(os.Stdin)
+	return reader.ReadString('\n')
+}
+
 func (s *FatSOauth1Service) GetRequestToken() error {
 	cacheName := "request_token"
-	cachedData := s.getCachedSecret(cacheName)
+	cachedData, err := s.getCachedSecret(cacheName)
+	if err != nil {
+		return err
+	}
 	if cachedData != nil && cachedData.Value != "" && cachedData.Value2 != "" {
 		s.authData.RequestToken = cachedData.Value
 		s.authData.RequestTokenSecret = cachedData.Value2
@@ -177,14 +239,9 @@ func (s *FatSOauth1Service) GetRequestToken() error {
 	}
 
 	reqData := url.Values{"oauth_callback": []string{"oob"}}
-	oauthParams, err := s.addOauthParams("POST", requestTokenUrl, reqData)
+	resp, respBody, err := s.makeSignedRequest("POST", requestTokenUrl, reqData)
 	if err != nil {
-		return fmt.Errorf("OAuth request token params error: %v", err)
-	}
-
-	resp, respBody, err := req_util.MakeHttpRequest("POST", requestTokenUrl, oauthParams, nil)
-	if err != nil {
-		return fmt.Errorf("OAuth request token creation error: %v", err)
+		return fmt.Errorf("OAuth request token creation error: %w", err)
 	}
 
 	if resp.StatusCode > 201 {
@@ -212,29 +269,26 @@ func (s *FatSOauth1Service) GetRequestToken() error {
 		return fmt.Errorf("OAuth response: there is no oauth_token_secret in response")
 	}
 
-	s.setCachedSecret(cacheName, s.authData.RequestToken, s.authData.RequestTokenSecret)
-	return nil
+	return s.setCachedSecret(cacheName, s.authData.RequestToken, s.authData.RequestTokenSecret)
 }
 
-func (s *FatSOauth1Service) getCachedSecret(name string) *fatSSecretData {
+func (s *FatSOauth1Service) getCachedSecret(name string) (*fatSSecretData, error) {
 	fileName := fmt.Sprintf("fatsecret_oauth_%s.json", name)
-	filePath := s.storage.GetFile(fileName, 0600)
 
-	data, err := os.ReadFile(filePath)
+	data, err := s.storage.ReadSecretFile(fileName)
 	if err != nil {
-		log.Fatalf("error reading FatSOauth1Service secret file: %v", err)
+		return nil, fmt.Errorf("error reading FatSOauth1Service secret file: %v", err)
 	}
 
 	res := fatSSecretData{}
 	if err := json.Unmarshal(data, &res); err != nil {
-		return nil
+		return nil, nil
 	}
-	return &res
+	return &res, nil
 }
 
-func (s *FatSOauth1Service) setCachedSecret(name string, value string, value2 string) {
+func (s *FatSOauth1Service) setCachedSecret(name string, value string, value2 string) error {
 	fileName := fmt.Sprintf("fatsecret_oauth_%s.json", name)
-	filePath := s.storage.GetFile(fileName, 0600)
 
 	data := fatSSecretData{
 		Value:  value,
@@ -245,20 +299,21 @@ func (s *FatSOauth1Service) setCachedSecret(name string, value string, value2 st
 	var res []byte
 	var err error
 	if res, err = json.Marshal(data); err != nil {
-		log.Fatalf("error when serializing secret data: %v", err)
+		return fmt.Errorf("error when serializing secret data: %v", err)
 	}
 
-	if err := os.WriteFile(filePath, res, 0600); err != nil {
-		log.Fatalf("error when writing secret data: %v", err)
+	if err := s.storage.WriteSecretFile(fileName, res); err != nil {
+		return fmt.Errorf("error when writing secret data: %v", err)
 	}
+	return nil
 }
 
 func (s *FatSOauth1Service) addOauthParams(reqMethod string, reqUrl string, reqData url.Values) (url.Values, error) {
-	nonceBuilder := strings.Builder{}
-	for i := 0; i < 8; i++ {
-		nonceBuilder.WriteString(strconv.FormatInt(rand.Int64()%10, 10))
+	rawNonce := make([]byte, 16)
+	if _, err := rand.Read(rawNonce); err != nil {
+		return nil, fmt.Errorf("error when generating nonce: %v", err)
 	}
-	nonce := nonceBuilder.String()
+	nonce := hex.EncodeToString(rawNonce)
 
 	vals := url.Values{}
 	for name, val := range reqData {
@@ -268,7 +323,7 @@ func (s *FatSOauth1Service) addOauthParams(reqMethod string, reqUrl string, reqD
 	vals.Set("oauth_consumer_key", url.QueryEscape(s.Keys.ConsumerKey))
 	vals.Set("oauth_nonce", nonce)
 	vals.Set("oauth_signature_method", "HMAC-SHA1")
-	vals.Set("oauth_timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	vals.Set("oauth_timestamp", strconv.FormatInt(time.Now().Add(s.clockOffset).Unix(), 10))
 	if s.authData.AccessToken != "" {
 		vals.Set("oauth_token", url.QueryEscape(s.authData.AccessToken))
 	}
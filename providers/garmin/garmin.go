@@ -0,0 +1,183 @@
+package garmin
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/fit"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const (
+	weightScaleWeightField = 0
+
+	sessionStartTimeField      = 2
+	sessionSportField          = 5
+	sessionTotalElapsedField   = 7
+	sessionTotalTimerTimeField = 8
+	sessionTotalDistanceField  = 9
+	sessionTotalCaloriesField  = 11
+
+	sportNameField = 3
+
+	activityLocalTimestampField = 5
+)
+
+var sportNames = []string{
+	"Generic", "Running", "Cycling", "Transition", "Fitness equipment", "Swimming", "Basketball", "Soccer",
+	"Tennis", "American football", "Training", "Walking", "Cross-country skiing", "Alpine skiing", "Snowboarding",
+	"Rowing", "Mountaineering", "Hiking", "Multisport", "Paddling", "Flying", "E-biking", "Motorcycling",
+	"Boating", "Driving", "Golf", "Hang gliding", "Horseback riding", "Hunting", "Fishing", "Inline skating",
+	"Rock climbing", "Sailing", "Ice skating", "Sky diving", "Snowshoeing", "Snowmobiling",
+	"Stand-up paddleboarding", "Surfing", "Wakeboarding", "Water skiing", "Kayaking", "Rafting", "Windsurfing",
+	"Kitesurfing", "Tactical", "Jumpmaster", "Boxing", "Floor climbing",
+}
+
+type Options struct {
+	FromDate time.Time
+	ToDate   time.Time
+}
+
+type fileData struct {
+	weights   []fatsecret.WeightData
+	exercises []fatsecret.ExerciseData
+	sportName string
+	location  *time.Location
+}
+
+func ReadFitFiles(inputPath string, opts Options) (*fatsecret.DiaryData, error) {
+	var fsys fs.FS
+	var files []string
+	if strings.HasSuffix(strings.ToLower(inputPath), ".fit") {
+		fsys = os.DirFS(filepath.Dir(inputPath))
+		files = []string{filepath.Base(inputPath)}
+	} else {
+		archive, closeArchive, err := import_util.OpenArchive(inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("Garmin: %v", err)
+		}
+		defer func() {
+			_ = closeArchive()
+		}()
+
+		fsys = archive
+		if files, err = import_util.FindFiles(fsys, func(name string) bool {
+			return strings.HasSuffix(name, ".fit")
+		}); err != nil {
+			return nil, fmt.Errorf("Garmin: %v", err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("Garmin: there are no FIT files in %s", inputPath)
+		}
+	}
+
+	builder := import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate)
+	seen := map[string]bool{}
+	for _, filePath := range files {
+		data, err := readFitFile(fsys, filePath)
+		if err != nil {
+			log.Printf("WARN: Garmin: skip %s: %v", filePath, err)
+			continue
+		}
+
+		for _, weight := range data.weights {
+			key := fmt.Sprintf("weight\x00%d\x00%f", weight.Date.Unix(), weight.WeightKg)
+			if !seen[key] {
+				seen[key] = true
+				weight.Date = weight.Date.In(time.Local)
+				builder.AddWeight(weight)
+			}
+		}
+		for _, exercise := range data.exercises {
+			key := fmt.Sprintf("exercise\x00%d\x00%s", exercise.Date.Unix(), exercise.Name)
+			if !seen[key] {
+				seen[key] = true
+				if data.sportName != "" && len(data.exercises) == 1 {
+					exercise.Name = data.sportName
+				}
+				exercise.Date = exercise.Date.In(data.location)
+				builder.AddExercise(exercise)
+			}
+		}
+	}
+
+	return builder.Build(), nil
+}
+
+func readFitFile(fsys fs.FS, filePath string) (*fileData, error) {
+	fp, err := fsys.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error when opening file: %v", err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	res := &fileData{location: time.Local}
+	err = fit.Decode(fp, func(msg fit.Message) error {
+		switch msg.Num {
+		case fit.MesgWeightScale:
+			readWeightScale(res, msg)
+		case fit.MesgSession:
+			readSession(res, msg)
+		case fit.MesgSport:
+			res.sportName, _ = msg.String(sportNameField)
+		case fit.MesgActivity:
+			timestamp, ok := msg.Uint(fit.TimestampField)
+			localTimestamp, localOk := msg.Uint(activityLocalTimestampField)
+			if ok && localOk {
+				res.location = time.FixedZone("", int(int64(localTimestamp)-int64(timestamp)))
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+func readWeightScale(res *fileData, msg fit.Message) {
+	date, ok := msg.Time(fit.TimestampField)
+	if !ok {
+		return
+	}
+	// 0xfffe means the scale is still calculating the weight
+	if raw, ok := msg.Uint(weightScaleWeightField); !ok || raw == 0xfffe {
+		return
+	}
+	weight, _ := msg.Scaled(weightScaleWeightField, 100)
+	res.weights = append(res.weights, fatsecret.WeightData{Date: date, WeightKg: weight})
+}
+
+func readSession(res *fileData, msg fit.Message) {
+	date, ok := msg.Time(sessionStartTimeField)
+	if !ok {
+		if date, ok = msg.Time(fit.TimestampField); !ok {
+			return
+		}
+	}
+
+	exercise := fatsecret.ExerciseData{Date: date}
+	if sport, ok := msg.Uint(sessionSportField); ok {
+		if sport < uint64(len(sportNames)) {
+			exercise.Name = sportNames[sport]
+		} else {
+			exercise.Name = fmt.Sprintf("Sport %d", sport)
+		}
+	}
+	if seconds, ok := msg.Scaled(sessionTotalTimerTimeField, 1000); ok {
+		exercise.DurationMinutes = seconds / 60
+	} else if seconds, ok := msg.Scaled(sessionTotalElapsedField, 1000); ok {
+		exercise.DurationMinutes = seconds / 60
+	}
+	if meters, ok := msg.Scaled(sessionTotalDistanceField, 100); ok {
+		exercise.DistanceKm = meters / 1000
+	}
+	exercise.Calories, _ = msg.Float(sessionTotalCaloriesField)
+
+	res.exercises = append(res.exercises, exercise)
+}
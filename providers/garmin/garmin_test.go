@@ -0,0 +1,101 @@
+package garmin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/andre487/data-migrators/utils/fit"
+)
+
+var testCrcTable = [16]uint16{
+	0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+	0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+func getTestCrc(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		for _, nibble := range []byte{b & 0x0f, b >> 4} {
+			tmp := testCrcTable[crc&0x0f]
+			crc = crc>>4&0x0fff ^ tmp ^ testCrcTable[nibble]
+		}
+	}
+	return crc
+}
+
+// Builds a FIT file without the header CRC, which is optional
+func buildTestFitFile(records ...[]byte) []byte {
+	data := bytes.Join(records, nil)
+	header := []byte{14, 0x20, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T', 0, 0}
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(data)))
+	res := append(header, data...)
+	return binary.LittleEndian.AppendUint16(res, getTestCrc(res))
+}
+
+// Fields are given as number, size and base type triples
+func buildTestDefinition(localNum byte, mesgNum uint16, fields ...byte) []byte {
+	res := []byte{0x40 | localNum, 0, 0}
+	res = binary.LittleEndian.AppendUint16(res, mesgNum)
+	return append(append(res, byte(len(fields)/3)), fields...)
+}
+
+func buildTestRecord(localNum byte, values ...interface{}) []byte {
+	res := []byte{localNum}
+	for _, val := range values {
+		switch val := val.(type) {
+		case uint8:
+			res = append(res, val)
+		case uint16:
+			res = binary.LittleEndian.AppendUint16(res, val)
+		case uint32:
+			res = binary.LittleEndian.AppendUint32(res, val)
+		}
+	}
+	return res
+}
+
+func TestReadFitFiles(t *testing.T) {
+	data := buildTestFitFile(
+		buildTestDefinition(0, fit.MesgWeightScale, fit.TimestampField, 4, 0x86, weightScaleWeightField, 2, 0x84),
+		buildTestRecord(0, uint32(1000), uint16(7250)),
+		// The scale is still calculating the weight
+		buildTestRecord(0, uint32(1100), uint16(0xfffe)),
+		buildTestDefinition(1, fit.MesgSession,
+			sessionStartTimeField, 4, 0x86, sessionSportField, 1, 0x00, sessionTotalTimerTimeField, 4, 0x86,
+			sessionTotalDistanceField, 4, 0x86, sessionTotalCaloriesField, 2, 0x84,
+		),
+		buildTestRecord(1, uint32(2000), uint8(1), uint32(1800000), uint32(510000), uint16(320)),
+		buildTestDefinition(2, fit.MesgActivity, fit.TimestampField, 4, 0x86, activityLocalTimestampField, 4, 0x86),
+		buildTestRecord(2, uint32(4000), uint32(4000+3*3600)),
+	)
+	filePath := path.Join(t.TempDir(), "activity.fit")
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diary, err := ReadFitFiles(filePath, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diary.Weights) != 1 {
+		t.Fatalf("expected 1 weight, got %+v", diary.Weights)
+	}
+	if weight := diary.Weights[0]; weight.WeightKg != 72.5 || !weight.Date.Equal(fit.Time(1000)) {
+		t.Errorf("unexpected weight %+v", weight)
+	}
+
+	if len(diary.Exercises) != 1 {
+		t.Fatalf("expected 1 exercise, got %+v", diary.Exercises)
+	}
+	exercise := diary.Exercises[0]
+	if exercise.Name != "Running" || exercise.DurationMinutes != 30 || exercise.DistanceKm != 5.1 || exercise.Calories != 320 {
+		t.Errorf("unexpected exercise %+v", exercise)
+	}
+	if _, offset := exercise.Date.Zone(); !exercise.Date.Equal(fit.Time(2000)) || offset != 3*3600 {
+		t.Errorf("unexpected exercise time %v", exercise.Date)
+	}
+}
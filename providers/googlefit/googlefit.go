@@ -0,0 +1,284 @@
+package googlefit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "googlefit"
+const nutritionType = "com.google.nutrition"
+const weightType = "com.google.weight"
+
+type Options struct {
+	FromDate time.Time
+	ToDate   time.Time
+	// Zone of the diary days, time.Local by default
+	Location *time.Location
+}
+
+type fitDataSource struct {
+	DataSource string         `json:"Data Source"`
+	DataPoints []fitDataPoint `json:"Data Points"`
+}
+
+type fitDataPoint struct {
+	DataTypeName       string     `json:"dataTypeName"`
+	OriginDataSourceId string     `json:"originDataSourceId"`
+	StartTimeNanos     fitNanos   `json:"startTimeNanos"`
+	EndTimeNanos       fitNanos   `json:"endTimeNanos"`
+	FitValue           []fitValue `json:"fitValue"`
+}
+
+type fitValue struct {
+	Value struct {
+		FpVal     *float64      `json:"fpVal"`
+		IntVal    *int64        `json:"intVal"`
+		StringVal *string       `json:"stringVal"`
+		MapVal    []fitMapValue `json:"mapVal"`
+	} `json:"value"`
+}
+
+type fitMapValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		FpVal *float64 `json:"fpVal"`
+	} `json:"value"`
+}
+
+type fitNanos int64
+
+func (n *fitNanos) UnmarshalJSON(data []byte) error {
+	res, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid nanoseconds timestamp %s: %v", data, err)
+	}
+	*n = fitNanos(res)
+	return nil
+}
+
+// Points are grouped into days of the user's zone, the one of the daily metrics dates
+func (n fitNanos) Time(loc *time.Location) time.Time {
+	return time.Unix(0, int64(n)).In(loc)
+}
+
+type fitNutrient struct {
+	Nutrient string
+	Scale    float64
+}
+
+var fitNutrients = map[string]fitNutrient{
+	"calories":            {"Calories", 1},
+	"protein":             {"Protein", 1},
+	"carbs.total":         {"Carbohydrate", 1},
+	"fat.total":           {"Fat", 1},
+	"dietary_fiber":       {"Fiber", 1},
+	"sugar":               {"Sugar", 1},
+	"fat.saturated":       {"SaturatedFat", 1},
+	"fat.monounsaturated": {"MonounsaturatedFat", 1},
+	"fat.polyunsaturated": {"PolyunsaturatedFat", 1},
+	"fat.trans":           {"TransFat", 1},
+	"cholesterol":         {"Cholesterol", 1},
+	"sodium":              {"Sodium", 1},
+	"potassium":           {"Potassium", 1},
+	"calcium":             {"Calcium", 100.0 / fatsecret.DailyValueCalciumMg},
+	"iron":                {"Iron", 100.0 / fatsecret.DailyValueIronMg},
+	"vitamin_c":           {"VitaminC", 100.0 / fatsecret.DailyValueVitaminCMg},
+}
+
+var fitMeals = map[int64]string{1: "Breakfast", 2: "Lunch", 3: "Dinner"}
+
+type reader struct {
+	builder    *import_util.DiaryBuilder
+	location   *time.Location
+	seen       map[string]bool
+	weightDays map[int64]bool
+}
+
+func ReadExport(exportPath string, opts Options) (*fatsecret.DiaryData, error) {
+	fsys, closeArchive, err := import_util.OpenArchive(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("Google Fit: %v", err)
+	}
+	defer func() {
+		_ = closeArchive()
+	}()
+
+	r := &reader{
+		builder:    import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate),
+		location:   opts.Location,
+		seen:       map[string]bool{},
+		weightDays: map[int64]bool{},
+	}
+	if r.location == nil {
+		r.location = time.Local
+	}
+
+	dataFiles, err := import_util.FindFiles(fsys, func(name string) bool {
+		return strings.HasSuffix(name, ".json") &&
+			(strings.Contains(name, nutritionType) || strings.Contains(name, weightType))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Google Fit: %v", err)
+	}
+	for _, filePath := range dataFiles {
+		if err := r.readDataFile(fsys, filePath); err != nil {
+			return nil, fmt.Errorf("Google Fit: %s: %v", filePath, err)
+		}
+	}
+
+	metricsFiles, err := import_util.FindFiles(fsys, func(name string) bool {
+		return name == "daily activity metrics.csv" || name == "daily summaries.csv"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Google Fit: %v", err)
+	}
+	for _, filePath := range metricsFiles {
+		if err := import_util.ReadCsvFile(fsys, filePath, ',', r.readDailyMetricsRecord); err != nil {
+			return nil, fmt.Errorf("Google Fit: %v", err)
+		}
+	}
+
+	if len(dataFiles) == 0 && len(metricsFiles) == 0 {
+		return nil, fmt.Errorf("Google Fit: there are no nutrition, weight or daily activity metrics files in %s", exportPath)
+	}
+	return r.builder.Build(), nil
+}
+
+func (r *reader) readDataFile(fsys fs.FS, filePath string) error {
+	fp, err := fsys.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error when opening file: %v", err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	data := fitDataSource{}
+	if err := json.NewDecoder(fp).Decode(&data); err != nil {
+		return fmt.Errorf("error when decoding JSON: %v", err)
+	}
+
+	for _, point := range data.DataPoints {
+		if point.DataTypeName != nutritionType && point.DataTypeName != weightType {
+			continue
+		}
+		if !r.builder.InRange(point.StartTimeNanos.Time(r.location)) {
+			continue
+		}
+
+		// Raw and merged data sources contain the same points with the same origin, so they are imported once
+		key := fmt.Sprintf("%s\x00%d\x00%d\x00%s", point.DataTypeName, point.StartTimeNanos, point.EndTimeNanos, point.OriginDataSourceId)
+		if r.seen[key] {
+			continue
+		}
+		r.seen[key] = true
+
+		switch point.DataTypeName {
+		case nutritionType:
+			r.readNutritionPoint(point, key)
+		case weightType:
+			r.readWeightPoint(point)
+		}
+	}
+	return nil
+}
+
+func (r *reader) readNutritionPoint(point fitDataPoint, key string) {
+	date := point.StartTimeNanos.Time(r.location)
+	entry := fatsecret.FoodEntryData{
+		Date:          date,
+		FoodEntryId:   import_util.SyntheticId(source, "entry", key),
+		FoodEntryName: "Food",
+		NumberOfUnits: 1,
+		Meal:          import_util.NormalizeMeal(""),
+	}
+	if len(point.FitValue) > 1 && point.FitValue[1].Value.IntVal != nil {
+		entry.Meal = import_util.NormalizeMeal(fitMeals[*point.FitValue[1].Value.IntVal])
+	}
+	if len(point.FitValue) > 2 && point.FitValue[2].Value.StringVal != nil && *point.FitValue[2].Value.StringVal != "" {
+		entry.FoodEntryName = *point.FitValue[2].Value.StringVal
+	}
+	entry.FoodId = import_util.SyntheticId(source, "food", entry.FoodEntryName)
+
+	present := map[string]bool{}
+	if len(point.FitValue) > 0 {
+		for _, item := range point.FitValue[0].Value.MapVal {
+			if item.Value.FpVal == nil {
+				continue
+			}
+			nutrient, ok := fitNutrients[item.Key]
+			if !ok {
+				if entry.OtherNutrients == nil {
+					entry.OtherNutrients = map[string]float64{}
+				}
+				entry.OtherNutrients[item.Key] = *item.Value.FpVal
+				continue
+			}
+			*entry.NutrientField(nutrient.Nutrient) = *item.Value.FpVal * nutrient.Scale
+			present[nutrient.Nutrient] = true
+		}
+	}
+	for _, name := range fatsecret.NutrientNames {
+		if !present[name] {
+			entry.MissingNutrients = append(entry.MissingNutrients, name)
+		}
+	}
+
+	r.builder.AddEntry(entry)
+}
+
+func (r *reader) readWeightPoint(point fitDataPoint) {
+	if len(point.FitValue) == 0 || point.FitValue[0].Value.FpVal == nil {
+		return
+	}
+	date := point.StartTimeNanos.Time(r.location)
+	dateInt, _ := import_util.DayDate(date)
+	r.weightDays[dateInt] = true
+	r.builder.AddWeight(fatsecret.WeightData{Date: date, WeightKg: *point.FitValue[0].Value.FpVal})
+}
+
+func (r *reader) readDailyMetricsRecord(rec import_util.CsvRecord) error {
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, r.location)
+	if !r.builder.InRange(date) {
+		return nil
+	}
+
+	dateInt, _ := import_util.DayDate(date)
+	weight, ok, err := rec.Float("Average weight (kg)")
+	if err != nil {
+		return err
+	}
+	if ok && !r.weightDays[dateInt] {
+		r.builder.AddWeight(fatsecret.WeightData{Date: date, WeightKg: weight})
+	}
+
+	exercise := fatsecret.ExerciseData{Date: date, Name: "Move Minutes"}
+	if exercise.DurationMinutes, _, err = rec.Float("Move Minutes count", "Move Minutes"); err != nil {
+		return err
+	}
+	distance, _, err := rec.Float("Distance (m)")
+	if err != nil {
+		return err
+	}
+	exercise.DistanceKm = distance / 1000
+	steps, _, err := rec.Float("Step count")
+	if err != nil {
+		return err
+	}
+	exercise.Steps = int64(steps)
+	if exercise.DurationMinutes > 0 || exercise.Steps > 0 {
+		r.builder.AddExercise(exercise)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package googlefit
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReadExportGroupsByLocalDays(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(path.Join(dir, "All Data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	loc := time.FixedZone("UTC-5", -5*3600)
+	// A dinner and a weigh-in of March 5 in UTC-5, which are March 6 in UTC
+	dinner := time.Date(2024, 3, 5, 21, 0, 0, 0, loc).UnixNano()
+	weighIn := time.Date(2024, 3, 5, 22, 0, 0, 0, loc).UnixNano()
+	files := map[string]string{
+		"All Data/derived_com.google.nutrition_com.google.android.gms.json": `{"Data Points": [{
+			"dataTypeName": "com.google.nutrition", "startTimeNanos": ` + strconv.FormatInt(dinner, 10) + `, "endTimeNanos": ` + strconv.FormatInt(dinner, 10) + `,
+			"fitValue": [{"value": {"mapVal": [{"key": "calories", "value": {"fpVal": 650}}]}}, {"value": {"intVal": 3}}]
+		}]}`,
+		"All Data/derived_com.google.weight_com.google.android.gms.json": `{"Data Points": [{
+			"dataTypeName": "com.google.weight", "startTimeNanos": ` + strconv.FormatInt(weighIn, 10) + `, "endTimeNanos": ` + strconv.FormatInt(weighIn, 10) + `,
+			"fitValue": [{"value": {"fpVal": 72.4}}]
+		}]}`,
+		"Daily activity metrics.csv": "Date,Average weight (kg),Step count\n2024-03-05,72.4,8000\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ReadExport(dir, Options{Location: loc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	if len(data.DiaryData) != 1 || !data.DiaryData[0].Date.Equal(expected) || data.DiaryData[0].Meal != "Dinner" {
+		t.Fatalf("expected a dinner of %v, got %+v", expected, data.DiaryData)
+	}
+	if len(data.Weights) != 1 || data.Weights[0].DateInt != data.DiaryData[0].DateInt {
+		t.Errorf("expected a weight of the dinner day, got %+v", data.Weights)
+	}
+	if len(data.Exercises) != 1 || data.Exercises[0].DateInt != data.DiaryData[0].DateInt || data.Exercises[0].Steps != 8000 {
+		t.Errorf("expected steps of the dinner day, got %+v", data.Exercises)
+	}
+}
@@ -0,0 +1,187 @@
+package loseit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "loseit"
+
+type Options struct {
+	FromDate   time.Time
+	ToDate     time.Time
+	WeightUnit string
+}
+
+var nutrientColumns = []import_util.NutrientColumn{
+	{Nutrient: "Calories", Columns: []string{"Calories"}},
+	{Nutrient: "Fat", Columns: []string{"Fat (g)"}},
+	{Nutrient: "Protein", Columns: []string{"Protein (g)"}},
+	{Nutrient: "Carbohydrate", Columns: []string{"Carbohydrates (g)"}},
+	{Nutrient: "SaturatedFat", Columns: []string{"Saturated Fat (g)"}},
+	{Nutrient: "Sugar", Columns: []string{"Sugars (g)"}},
+	{Nutrient: "Fiber", Columns: []string{"Fiber (g)"}},
+	{Nutrient: "Cholesterol", Columns: []string{"Cholesterol (mg)"}},
+	{Nutrient: "Sodium", Columns: []string{"Sodium (mg)"}},
+	{Nutrient: "Potassium", Columns: []string{"Potassium (mg)"}},
+	{Nutrient: "MonounsaturatedFat", Columns: []string{"Monounsaturated Fat (g)"}},
+	{Nutrient: "PolyunsaturatedFat", Columns: []string{"Polyunsaturated Fat (g)"}},
+	{Nutrient: "TransFat", Columns: []string{"Trans Fat (g)"}},
+	{Nutrient: "VitaminA", Columns: []string{"Vitamin A (%)"}},
+	{Nutrient: "VitaminC", Columns: []string{"Vitamin C (%)"}},
+	{Nutrient: "Calcium", Columns: []string{"Calcium (%)"}},
+	{Nutrient: "Iron", Columns: []string{"Iron (%)"}},
+}
+
+func ReadExport(exportPath string, opts Options) (*fatsecret.DiaryData, error) {
+	weightScale, err := import_util.WeightUnitScale(opts.WeightUnit)
+	if err != nil {
+		return nil, fmt.Errorf("Lose It!: %v", err)
+	}
+
+	fsys, closeArchive, err := import_util.OpenArchive(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("Lose It!: %v", err)
+	}
+	defer func() {
+		_ = closeArchive()
+	}()
+
+	builder := import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate)
+	entryIds := map[string]int{}
+	readers := []struct {
+		Prefix string
+		Read   func(rec import_util.CsvRecord) error
+	}{
+		{"food-logs", func(rec import_util.CsvRecord) error { return readFoodLogRecord(builder, rec, entryIds) }},
+		{"exercise-logs", func(rec import_util.CsvRecord) error { return readExerciseLogRecord(builder, rec) }},
+		{"weights", func(rec import_util.CsvRecord) error { return readWeightRecord(builder, rec, weightScale) }},
+	}
+
+	filesCount := 0
+	for _, reader := range readers {
+		files, err := import_util.FindFiles(fsys, func(name string) bool {
+			return strings.HasPrefix(name, reader.Prefix) && strings.HasSuffix(name, ".csv")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Lose It!: %v", err)
+		}
+		for _, filePath := range files {
+			if err := import_util.ReadCsvFile(fsys, filePath, ',', reader.Read); err != nil {
+				return nil, fmt.Errorf("Lose It!: %v", err)
+			}
+			filesCount++
+		}
+	}
+	if filesCount == 0 {
+		return nil, fmt.Errorf("Lose It!: there are no food logs, exercise logs or weights CSV files in %s", exportPath)
+	}
+
+	return builder.Build(), nil
+}
+
+func readFoodLogRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord, entryIds map[string]int) error {
+	if isDeleted(rec) {
+		return nil
+	}
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+	if !builder.InRange(date) {
+		return nil
+	}
+
+	name := rec.Get("Name")
+	meal := rec.Get("Meal")
+	quantity := rec.Get("Quantity")
+	units := rec.Get("Units")
+	entry := fatsecret.FoodEntryData{
+		Date:                 date,
+		FoodId:               import_util.SyntheticId(source, "food", name),
+		FoodEntryName:        name,
+		FoodEntryDescription: strings.TrimSpace(quantity + " " + units),
+		NumberOfUnits:        1,
+		Meal:                 import_util.NormalizeMeal(meal),
+	}
+	if val, err := strconv.ParseFloat(quantity, 64); err == nil && val > 0 {
+		entry.NumberOfUnits = val
+	}
+
+	entryKey := strings.Join([]string{date.Format(time.DateOnly), meal, name, quantity, units}, "\x00")
+	entry.FoodEntryId = import_util.SyntheticId(source, "entry", entryKey, strconv.Itoa(entryIds[entryKey]))
+	entryIds[entryKey]++
+
+	if err := import_util.ReadNutrients(rec, &entry, nutrientColumns); err != nil {
+		return err
+	}
+
+	builder.AddEntry(entry)
+	return nil
+}
+
+func readExerciseLogRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	if isDeleted(rec) {
+		return nil
+	}
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	exercise := fatsecret.ExerciseData{
+		Date: date,
+		Name: rec.Get("Name"),
+	}
+	if exercise.Calories, _, err = rec.Float("Calories"); err != nil {
+		return err
+	}
+	quantity, _, err := rec.Float("Quantity")
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(rec.Get("Units")) {
+	case "minute", "minutes", "min":
+		exercise.DurationMinutes = quantity
+	case "hour", "hours", "hr":
+		exercise.DurationMinutes = quantity * 60
+	}
+
+	builder.AddExercise(exercise)
+	return nil
+}
+
+func readWeightRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord, weightScale float64) error {
+	if isDeleted(rec) {
+		return nil
+	}
+	weight, ok, err := rec.Float("Weight")
+	if err != nil || !ok {
+		return err
+	}
+
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	builder.AddWeight(fatsecret.WeightData{
+		Date:     date,
+		WeightKg: weight * weightScale,
+	})
+	return nil
+}
+
+func isDeleted(rec import_util.CsvRecord) bool {
+	switch strings.ToLower(rec.Get("Deleted")) {
+	case "1", "true", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,155 @@
+package myfitnesspal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "myfitnesspal"
+
+type Options struct {
+	FromDate   time.Time
+	ToDate     time.Time
+	WeightUnit string
+}
+
+var nutrientColumns = []import_util.NutrientColumn{
+	{Nutrient: "Calories", Columns: []string{"Calories"}},
+	{Nutrient: "Fat", Columns: []string{"Fat (g)", "Fat"}},
+	{Nutrient: "SaturatedFat", Columns: []string{"Saturated Fat"}},
+	{Nutrient: "PolyunsaturatedFat", Columns: []string{"Polyunsaturated Fat"}},
+	{Nutrient: "MonounsaturatedFat", Columns: []string{"Monounsaturated Fat"}},
+	{Nutrient: "TransFat", Columns: []string{"Trans Fat"}},
+	{Nutrient: "Cholesterol", Columns: []string{"Cholesterol"}},
+	{Nutrient: "Sodium", Columns: []string{"Sodium (mg)", "Sodium"}},
+	{Nutrient: "Potassium", Columns: []string{"Potassium"}},
+	{Nutrient: "Carbohydrate", Columns: []string{"Carbohydrates (g)", "Carbohydrates"}},
+	{Nutrient: "Fiber", Columns: []string{"Fiber"}},
+	{Nutrient: "Sugar", Columns: []string{"Sugar"}},
+	{Nutrient: "Protein", Columns: []string{"Protein (g)", "Protein"}},
+	{Nutrient: "VitaminA", Columns: []string{"Vitamin A"}},
+	{Nutrient: "VitaminC", Columns: []string{"Vitamin C"}},
+	{Nutrient: "Calcium", Columns: []string{"Calcium"}},
+	{Nutrient: "Iron", Columns: []string{"Iron"}},
+}
+
+func ReadExport(exportPath string, opts Options) (*fatsecret.DiaryData, error) {
+	weightScale, err := import_util.WeightUnitScale(opts.WeightUnit)
+	if err != nil {
+		return nil, fmt.Errorf("MyFitnessPal: %v", err)
+	}
+
+	fsys, closeArchive, err := import_util.OpenArchive(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("MyFitnessPal: %v", err)
+	}
+	defer func() {
+		_ = closeArchive()
+	}()
+
+	builder := import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate)
+	readers := []struct {
+		Prefix string
+		Read   func(rec import_util.CsvRecord) error
+	}{
+		{"nutrition-summary", func(rec import_util.CsvRecord) error { return readNutritionRecord(builder, rec) }},
+		{"exercise-summary", func(rec import_util.CsvRecord) error { return readExerciseRecord(builder, rec) }},
+		{"measurement-summary", func(rec import_util.CsvRecord) error { return readMeasurementRecord(builder, rec, weightScale) }},
+	}
+
+	filesCount := 0
+	for _, reader := range readers {
+		files, err := import_util.FindFiles(fsys, func(name string) bool {
+			return strings.HasPrefix(name, reader.Prefix) && strings.HasSuffix(name, ".csv")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("MyFitnessPal: %v", err)
+		}
+		for _, filePath := range files {
+			if err := import_util.ReadCsvFile(fsys, filePath, ',', reader.Read); err != nil {
+				return nil, fmt.Errorf("MyFitnessPal: %v", err)
+			}
+			filesCount++
+		}
+	}
+	if filesCount == 0 {
+		return nil, fmt.Errorf("MyFitnessPal: there are no summary CSV files in %s", exportPath)
+	}
+
+	return builder.Build(), nil
+}
+
+func readNutritionRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+	if !builder.InRange(date) {
+		return nil
+	}
+
+	meal := rec.Get("Meal")
+	entry := fatsecret.FoodEntryData{
+		Date:                 date,
+		FoodId:               import_util.SyntheticId(source, "meal", meal),
+		FoodEntryId:          import_util.SyntheticId(source, "entry", date.Format(time.DateOnly), meal),
+		FoodEntryName:        meal,
+		FoodEntryDescription: rec.Get("Note"),
+		NumberOfUnits:        1,
+		Meal:                 import_util.NormalizeMeal(meal),
+	}
+	if err := import_util.ReadNutrients(rec, &entry, nutrientColumns); err != nil {
+		return err
+	}
+
+	builder.AddEntry(entry)
+	return nil
+}
+
+func readExerciseRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	exercise := fatsecret.ExerciseData{
+		Date: date,
+		Name: rec.Get("Exercise"),
+	}
+	if exercise.Calories, _, err = rec.Float("Exercise Calories", "Calories"); err != nil {
+		return err
+	}
+	if exercise.DurationMinutes, _, err = rec.Float("Exercise Minutes", "Minutes"); err != nil {
+		return err
+	}
+	steps, _, err := rec.Float("Steps")
+	if err != nil {
+		return err
+	}
+	exercise.Steps = int64(steps)
+
+	builder.AddExercise(exercise)
+	return nil
+}
+
+func readMeasurementRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord, weightScale float64) error {
+	weight, ok, err := rec.Float("Weight")
+	if err != nil || !ok {
+		return err
+	}
+
+	date, err := import_util.ParseDate(rec.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	builder.AddWeight(fatsecret.WeightData{
+		Date:     date,
+		WeightKg: weight * weightScale,
+	})
+	return nil
+}
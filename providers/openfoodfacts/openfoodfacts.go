@@ -0,0 +1,527 @@
+package openfoodfacts
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/sinks/sqlite"
+	"github.com/andre487/data-migrators/transforms"
+	"github.com/andre487/data-migrators/utils/import_util"
+	"github.com/andre487/data-migrators/utils/storage"
+)
+
+const loadBatchSize = 10000
+
+var migrations = []string{
+	`CREATE TABLE products (
+		code TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		brands TEXT NOT NULL,
+		nova_group INTEGER NOT NULL,
+		ingredients TEXT NOT NULL,
+		serving_grams REAL NOT NULL,
+		popularity INTEGER NOT NULL,
+		nutrients TEXT NOT NULL
+	);
+	CREATE TABLE product_names (
+		normalized_name TEXT NOT NULL,
+		code TEXT NOT NULL REFERENCES products (code) ON DELETE CASCADE,
+		popularity INTEGER NOT NULL,
+		PRIMARY KEY (normalized_name, code)
+	);
+	CREATE INDEX product_names_code ON product_names (code);
+	CREATE TABLE dumps (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		loaded_at TEXT NOT NULL,
+		products_count INTEGER NOT NULL
+	);`,
+}
+
+const upsertProductQuery = `INSERT INTO products (code, name, brands, nova_group, ingredients, serving_grams, popularity, nutrients)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (code) DO UPDATE SET
+		name = excluded.name,
+		brands = excluded.brands,
+		nova_group = excluded.nova_group,
+		ingredients = excluded.ingredients,
+		serving_grams = excluded.serving_grams,
+		popularity = excluded.popularity,
+		nutrients = excluded.nutrients`
+
+const selectProductQuery = `SELECT code, name, brands, nova_group, ingredients, serving_grams, nutrients FROM products`
+
+type offNutrient struct {
+	Nutrient string
+	Scale    float64
+}
+
+// Open Food Facts keeps nutrients in g per 100 g, they are converted to FatSecret's units when loaded
+var offNutrients = map[string]offNutrient{
+	"energy-kcal_100g":         {"Calories", 1},
+	"proteins_100g":            {"Protein", 1},
+	"carbohydrates_100g":       {"Carbohydrate", 1},
+	"fat_100g":                 {"Fat", 1},
+	"fiber_100g":               {"Fiber", 1},
+	"sugars_100g":              {"Sugar", 1},
+	"saturated-fat_100g":       {"SaturatedFat", 1},
+	"monounsaturated-fat_100g": {"MonounsaturatedFat", 1},
+	"polyunsaturated-fat_100g": {"PolyunsaturatedFat", 1},
+	"trans-fat_100g":           {"TransFat", 1},
+	"cholesterol_100g":         {"Cholesterol", 1000},
+	"sodium_100g":              {"Sodium", 1000},
+	"potassium_100g":           {"Potassium", 1000},
+	"calcium_100g":             {"Calcium", 1000 * 100 / fatsecret.DailyValueCalciumMg},
+	"iron_100g":                {"Iron", 1000 * 100 / fatsecret.DailyValueIronMg},
+	"vitamin-c_100g":           {"VitaminC", 1000 * 100 / fatsecret.DailyValueVitaminCMg},
+}
+
+type Product struct {
+	Code         string
+	Name         string
+	Brands       string
+	NovaGroup    int
+	Ingredients  string
+	ServingGrams float64
+	Popularity   int64
+	Nutrients    map[string]float64
+}
+
+type Index struct {
+	db        *sql.DB
+	nameCache map[string]*Product
+}
+
+type offProduct struct {
+	Code            string                 `json:"code"`
+	ProductName     string                 `json:"product_name"`
+	Brands          string                 `json:"brands"`
+	NovaGroup       interface{}            `json:"nova_group"`
+	IngredientsText string                 `json:"ingredients_text"`
+	ServingQuantity interface{}            `json:"serving_quantity"`
+	UniqueScansN    interface{}            `json:"unique_scans_n"`
+	Nutriments      map[string]interface{} `json:"nutriments"`
+}
+
+func DefaultIndexPath() (string, error) {
+	offStorage, err := storage.New("openfoodfacts")
+	if err != nil {
+		return "", fmt.Errorf("Open Food Facts: %v", err)
+	}
+	res, err := offStorage.GetFile("products.db", 0644)
+	if err != nil {
+		return "", fmt.Errorf("Open Food Facts: %v", err)
+	}
+	return res, nil
+}
+
+func OpenIndex(dbPath string) (*Index, error) {
+	if dbPath == "" {
+		var err error
+		if dbPath, err = DefaultIndexPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("Open Food Facts: error when opening index: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("Open Food Facts: error when enabling foreign keys: %v", err)
+	}
+	if err := sqlite.Migrate(db, migrations); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("Open Food Facts: %v", err)
+	}
+	return &Index{db: db, nameCache: map[string]*Product{}}, nil
+}
+
+func (ix *Index) Close() error {
+	if err := ix.db.Close(); err != nil {
+		return fmt.Errorf("Open Food Facts: error when closing index: %v", err)
+	}
+	return nil
+}
+
+func (ix *Index) Load(dumpPath string) (int, error) {
+	fp, err := os.Open(dumpPath)
+	if err != nil {
+		return 0, fmt.Errorf("Open Food Facts: error when opening dump: %v", err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	r, format, err := openDump(fp, dumpPath)
+	if err != nil {
+		return 0, fmt.Errorf("Open Food Facts: %v", err)
+	}
+
+	loader := &indexLoader{db: ix.db}
+	defer loader.rollback()
+	switch format {
+	case "jsonl":
+		err = readJsonlDump(r, loader.add)
+	default:
+		err = readCsvDump(r, loader.add)
+	}
+	if err == nil {
+		err = loader.commit()
+	}
+	if err != nil {
+		return loader.count, fmt.Errorf("Open Food Facts: %v", err)
+	}
+
+	_, err = ix.db.Exec(
+		"INSERT INTO dumps (path, loaded_at, products_count) VALUES (?, ?, ?)",
+		dumpPath, time.Now().UTC().Format(time.RFC3339), loader.count,
+	)
+	if err != nil {
+		return loader.count, fmt.Errorf("Open Food Facts: error when saving dump info: %v", err)
+	}
+	ix.nameCache = map[string]*Product{}
+	return loader.count, nil
+}
+
+func (ix *Index) Count() (int, error) {
+	var res int
+	if err := ix.db.QueryRow("SELECT COUNT(*) FROM products").Scan(&res); err != nil {
+		return 0, fmt.Errorf("Open Food Facts: error when counting products: %v", err)
+	}
+	return res, nil
+}
+
+func (ix *Index) FindByBarcode(code string) (*Product, error) {
+	codes := []string{code}
+	if len(code) == 12 {
+		codes = append(codes, "0"+code)
+	} else if len(code) == 13 && code[0] == '0' {
+		codes = append(codes, code[1:])
+	}
+
+	for _, item := range codes {
+		res, err := ix.queryProduct(selectProductQuery+" WHERE code = ?", item)
+		if err != nil || res != nil {
+			return res, err
+		}
+	}
+	return nil, nil
+}
+
+func (ix *Index) FindByName(name string) (*Product, error) {
+	normalized := normalizeName(name)
+	if normalized == "" {
+		return nil, nil
+	}
+	if res, ok := ix.nameCache[normalized]; ok {
+		return res, nil
+	}
+
+	res, err := ix.queryProduct(
+		selectProductQuery+` WHERE code = (
+			SELECT code FROM product_names WHERE normalized_name = ? ORDER BY popularity DESC, code LIMIT 1
+		)`,
+		normalized,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ix.nameCache[normalized] = res
+	return res, nil
+}
+
+func (ix *Index) Name() string {
+	return "Open Food Facts"
+}
+
+func (ix *Index) EnrichEntry(entry *fatsecret.FoodEntryData) (bool, error) {
+	var product *Product
+	var err error
+	barcode := transforms.GetEntryBarcode(entry)
+	if barcode != "" {
+		if product, err = ix.FindByBarcode(barcode); err != nil {
+			return false, err
+		}
+	}
+	if product == nil {
+		if product, err = ix.FindByName(entry.FoodEntryName); err != nil {
+			return false, err
+		}
+	}
+	if product == nil {
+		return false, nil
+	}
+
+	grams := transforms.GetEntryGrams(entry, product.Nutrients, product.ServingGrams)
+	changed := transforms.FillMissingNutrients(entry, product.Nutrients, grams) > 0
+	if entry.NovaGroup == 0 && product.NovaGroup > 0 {
+		entry.NovaGroup = product.NovaGroup
+		changed = true
+	}
+	if entry.Ingredients == "" && product.Ingredients != "" {
+		entry.Ingredients = product.Ingredients
+		changed = true
+	}
+	if entry.Barcode == "" && barcode != "" {
+		entry.Barcode = product.Code
+		changed = true
+	}
+	return changed, nil
+}
+
+func (ix *Index) queryProduct(query string, args ...interface{}) (*Product, error) {
+	res := &Product{}
+	var nutrients string
+	err := ix.db.QueryRow(query, args...).Scan(
+		&res.Code, &res.Name, &res.Brands, &res.NovaGroup, &res.Ingredients, &res.ServingGrams, &nutrients,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Open Food Facts: error when querying product: %v", err)
+	}
+	if err := json.Unmarshal([]byte(nutrients), &res.Nutrients); err != nil {
+		return nil, fmt.Errorf("Open Food Facts: invalid nutrients of product %s: %v", res.Code, err)
+	}
+	return res, nil
+}
+
+type indexLoader struct {
+	db    *sql.DB
+	tx    *sql.Tx
+	count int
+}
+
+func (l *indexLoader) add(product *Product) error {
+	if l.tx == nil {
+		var err error
+		if l.tx, err = l.db.Begin(); err != nil {
+			return fmt.Errorf("error when starting transaction: %v", err)
+		}
+	}
+
+	nutrients, err := json.Marshal(product.Nutrients)
+	if err != nil {
+		return fmt.Errorf("error when encoding nutrients of product %s: %v", product.Code, err)
+	}
+	_, err = l.tx.Exec(
+		upsertProductQuery, product.Code, product.Name, product.Brands, product.NovaGroup,
+		product.Ingredients, product.ServingGrams, product.Popularity, string(nutrients),
+	)
+	if err != nil {
+		return fmt.Errorf("error when saving product %s: %v", product.Code, err)
+	}
+
+	if _, err := l.tx.Exec("DELETE FROM product_names WHERE code = ?", product.Code); err != nil {
+		return fmt.Errorf("error when saving product %s names: %v", product.Code, err)
+	}
+	for _, name := range getProductNames(product) {
+		_, err := l.tx.Exec(
+			"INSERT OR IGNORE INTO product_names (normalized_name, code, popularity) VALUES (?, ?, ?)",
+			name, product.Code, product.Popularity,
+		)
+		if err != nil {
+			return fmt.Errorf("error when saving product %s names: %v", product.Code, err)
+		}
+	}
+
+	l.count++
+	if l.count%loadBatchSize == 0 {
+		if err := l.commit(); err != nil {
+			return err
+		}
+	}
+	if l.count%(loadBatchSize*10) == 0 {
+		log.Printf("Open Food Facts: loaded %d products", l.count)
+	}
+	return nil
+}
+
+func (l *indexLoader) commit() error {
+	if l.tx == nil {
+		return nil
+	}
+	tx := l.tx
+	l.tx = nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error when committing products: %v", err)
+	}
+	return nil
+}
+
+func (l *indexLoader) rollback() {
+	if l.tx != nil {
+		if err := l.tx.Rollback(); err != nil {
+			log.Printf("WARN: Open Food Facts: error when rolling back transaction: %v", err)
+		}
+		l.tx = nil
+	}
+}
+
+func openDump(r io.Reader, dumpPath string) (io.Reader, string, error) {
+	name := strings.ToLower(dumpPath)
+	var res io.Reader = bufio.NewReaderSize(r, 1<<20)
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		gr, err := gzip.NewReader(res)
+		if err != nil {
+			return nil, "", fmt.Errorf("error when opening gzip dump: %v", err)
+		}
+		res = gr
+		name = strings.TrimSuffix(name, ".gz")
+	case strings.HasSuffix(name, ".zst"):
+		zr, err := zstd.NewReader(res)
+		if err != nil {
+			return nil, "", fmt.Errorf("error when opening zstd dump: %v", err)
+		}
+		res = zr.IOReadCloser()
+		name = strings.TrimSuffix(name, ".zst")
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".json"):
+		return res, "jsonl", nil
+	case strings.HasSuffix(name, ".csv"), strings.HasSuffix(name, ".tsv"):
+		return res, "csv", nil
+	default:
+		return nil, "", fmt.Errorf("unknown dump format of %s, expected .jsonl or .csv, optionally with .gz or .zst", dumpPath)
+	}
+}
+
+func readJsonlDump(r io.Reader, add func(product *Product) error) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		item := offProduct{}
+		err := decoder.Decode(&item)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error when decoding product %d: %v", line, err)
+		}
+
+		product := &Product{
+			Code:         strings.TrimSpace(item.Code),
+			Name:         strings.TrimSpace(item.ProductName),
+			Brands:       strings.TrimSpace(item.Brands),
+			NovaGroup:    int(parseNumber(item.NovaGroup)),
+			Ingredients:  strings.TrimSpace(item.IngredientsText),
+			ServingGrams: parseNumber(item.ServingQuantity),
+			Popularity:   int64(parseNumber(item.UniqueScansN)),
+			Nutrients:    map[string]float64{},
+		}
+		nutriments := map[string]float64{}
+		for key, val := range item.Nutriments {
+			if number, ok := parseOptionalNumber(val); ok {
+				nutriments[key] = number
+			}
+		}
+		readNutriments(product, nutriments)
+
+		if isUsefulProduct(product) {
+			if err := add(product); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readCsvDump(r io.Reader, add func(product *Product) error) error {
+	return import_util.ReadCsv(r, '\t', func(rec import_util.CsvRecord) error {
+		product := &Product{
+			Code:        rec.Get("code"),
+			Name:        rec.Get("product_name"),
+			Brands:      rec.Get("brands"),
+			Ingredients: rec.Get("ingredients_text"),
+			Nutrients:   map[string]float64{},
+		}
+		product.NovaGroup = int(parseNumber(rec.Get("nova_group")))
+		product.ServingGrams = parseNumber(rec.Get("serving_quantity"))
+		product.Popularity = int64(parseNumber(rec.Get("unique_scans_n")))
+
+		nutriments := map[string]float64{}
+		for key := range offNutrients {
+			if val, ok := parseOptionalNumber(rec.Get(key)); ok {
+				nutriments[key] = val
+			}
+		}
+		if val, ok := parseOptionalNumber(rec.Get("energy_100g")); ok {
+			nutriments["energy_100g"] = val
+		}
+		readNutriments(product, nutriments)
+
+		if !isUsefulProduct(product) {
+			return nil
+		}
+		return add(product)
+	})
+}
+
+func readNutriments(product *Product, nutriments map[string]float64) {
+	for key, nutrient := range offNutrients {
+		if val, ok := nutriments[key]; ok {
+			product.Nutrients[nutrient.Nutrient] = val * nutrient.Scale
+		}
+	}
+	if _, ok := product.Nutrients["Calories"]; !ok {
+		if kj, ok := nutriments["energy_100g"]; ok {
+			product.Nutrients["Calories"] = kj / 4.184
+		}
+	}
+}
+
+func isUsefulProduct(product *Product) bool {
+	return product.Code != "" && (product.Name != "" || len(product.Nutrients) > 0 || product.NovaGroup > 0)
+}
+
+func getProductNames(product *Product) []string {
+	name := normalizeName(product.Name)
+	if name == "" {
+		return nil
+	}
+	res := []string{name}
+	brand, _, _ := strings.Cut(product.Brands, ",")
+	if brand = normalizeName(brand); brand != "" && !strings.HasPrefix(name, brand) {
+		res = append(res, brand+" "+name)
+	}
+	return res
+}
+
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+func parseNumber(val interface{}) float64 {
+	res, _ := parseOptionalNumber(val)
+	return res
+}
+
+func parseOptionalNumber(val interface{}) (float64, bool) {
+	switch typed := val.(type) {
+	case float64:
+		return typed, true
+	case string:
+		res, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+		return res, err == nil
+	default:
+		return 0, false
+	}
+}
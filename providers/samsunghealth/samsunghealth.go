@@ -0,0 +1,254 @@
+package samsunghealth
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/import_util"
+)
+
+const source = "samsunghealth"
+const foodIntakeType = "com.samsung.health.food_intake"
+const foodInfoType = "com.samsung.health.food_info"
+const weightType = "com.samsung.health.weight"
+
+type Options struct {
+	FromDate time.Time
+	ToDate   time.Time
+}
+
+// Vitamins and minerals of food_info are already in % of daily value like in FatSecret
+var nutrientColumns = []import_util.NutrientColumn{
+	{Nutrient: "Calories", Columns: samsungColumns(foodInfoType, "calorie")},
+	{Nutrient: "Protein", Columns: samsungColumns(foodInfoType, "protein")},
+	{Nutrient: "Carbohydrate", Columns: samsungColumns(foodInfoType, "carbohydrate")},
+	{Nutrient: "Fat", Columns: samsungColumns(foodInfoType, "total_fat")},
+	{Nutrient: "Fiber", Columns: samsungColumns(foodInfoType, "dietary_fiber")},
+	{Nutrient: "Sugar", Columns: samsungColumns(foodInfoType, "sugar")},
+	{Nutrient: "SaturatedFat", Columns: samsungColumns(foodInfoType, "saturated_fat")},
+	{Nutrient: "MonounsaturatedFat", Columns: samsungColumns(foodInfoType, "monosaturated_fat")},
+	{Nutrient: "PolyunsaturatedFat", Columns: samsungColumns(foodInfoType, "polysaturated_fat")},
+	{Nutrient: "TransFat", Columns: samsungColumns(foodInfoType, "trans_fat")},
+	{Nutrient: "Cholesterol", Columns: samsungColumns(foodInfoType, "cholesterol")},
+	{Nutrient: "Sodium", Columns: samsungColumns(foodInfoType, "sodium")},
+	{Nutrient: "Potassium", Columns: samsungColumns(foodInfoType, "potassium")},
+	{Nutrient: "VitaminA", Columns: samsungColumns(foodInfoType, "vitamin_a")},
+	{Nutrient: "VitaminC", Columns: samsungColumns(foodInfoType, "vitamin_c")},
+	{Nutrient: "Calcium", Columns: samsungColumns(foodInfoType, "calcium")},
+	{Nutrient: "Iron", Columns: samsungColumns(foodInfoType, "iron")},
+}
+
+var mealTypes = map[string]string{
+	"100001": "Breakfast",
+	"100002": "Lunch",
+	"100003": "Dinner",
+	"100004": "Morning snack",
+	"100005": "Afternoon snack",
+	"100006": "Evening snack",
+}
+
+type foodInfo struct {
+	entry              fatsecret.FoodEntryData
+	servingDescription string
+}
+
+func ReadExport(exportPath string, opts Options) (*fatsecret.DiaryData, error) {
+	fsys, closeArchive, err := import_util.OpenArchive(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("Samsung Health: %v", err)
+	}
+	defer func() {
+		_ = closeArchive()
+	}()
+
+	builder := import_util.NewDiaryBuilder(opts.FromDate, opts.ToDate)
+	foods := map[string]foodInfo{}
+	readers := []struct {
+		Type string
+		Read func(rec import_util.CsvRecord) error
+	}{
+		{foodInfoType, func(rec import_util.CsvRecord) error { return readFoodInfoRecord(foods, rec) }},
+		{foodIntakeType, func(rec import_util.CsvRecord) error { return readFoodIntakeRecord(builder, foods, rec) }},
+		{weightType, func(rec import_util.CsvRecord) error { return readWeightRecord(builder, rec) }},
+	}
+
+	filesCount := 0
+	for _, reader := range readers {
+		files, err := import_util.FindFiles(fsys, func(name string) bool {
+			return strings.HasPrefix(name, reader.Type+".") && strings.HasSuffix(name, ".csv")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Samsung Health: %v", err)
+		}
+		for _, filePath := range files {
+			if err := readSamsungCsvFile(fsys, filePath, reader.Read); err != nil {
+				return nil, fmt.Errorf("Samsung Health: %v", err)
+			}
+			filesCount++
+		}
+	}
+	if filesCount == 0 {
+		return nil, fmt.Errorf("Samsung Health: there are no food intake, food info or weight CSV files in %s", exportPath)
+	}
+
+	return builder.Build(), nil
+}
+
+func readSamsungCsvFile(fsys fs.FS, filePath string, fn func(rec import_util.CsvRecord) error) error {
+	fp, err := fsys.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error when opening %s: %v", filePath, err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	// The first line is metadata: data type, its version and the number of records
+	reader := bufio.NewReader(fp)
+	if _, err := reader.ReadString('\n'); err != nil {
+		return fmt.Errorf("%s: error when reading metadata line: %v", filePath, err)
+	}
+	if err := import_util.ReadCsv(reader, ',', fn); err != nil {
+		return fmt.Errorf("%s: %v", filePath, err)
+	}
+	return nil
+}
+
+func readFoodInfoRecord(foods map[string]foodInfo, rec import_util.CsvRecord) error {
+	id := rec.Get(samsungColumns(foodInfoType, "datauuid")...)
+	if id == "" {
+		return nil
+	}
+
+	info := foodInfo{
+		entry: fatsecret.FoodEntryData{
+			FoodId:        import_util.SyntheticId(source, "food", id),
+			FoodEntryName: rec.Get(samsungColumns(foodInfoType, "name")...),
+		},
+		servingDescription: rec.Get(samsungColumns(foodInfoType, "serving_description")...),
+	}
+	if err := import_util.ReadNutrients(rec, &info.entry, nutrientColumns); err != nil {
+		return err
+	}
+
+	foods[id] = info
+	return nil
+}
+
+func readFoodIntakeRecord(builder *import_util.DiaryBuilder, foods map[string]foodInfo, rec import_util.CsvRecord) error {
+	date, err := getRecordTime(rec, foodIntakeType)
+	if err != nil {
+		return err
+	}
+	if !builder.InRange(date) {
+		return nil
+	}
+
+	foodInfoId := rec.Get(samsungColumns(foodIntakeType, "food_info_id")...)
+	info, ok := foods[foodInfoId]
+	if !ok {
+		info.entry = fatsecret.FoodEntryData{FoodId: import_util.SyntheticId(source, "food", foodInfoId)}
+		for _, name := range fatsecret.NutrientNames {
+			if name != "Calories" {
+				info.entry.MissingNutrients = append(info.entry.MissingNutrients, name)
+			}
+		}
+	}
+
+	entry := info.entry
+	entry.MissingNutrients = append([]string{}, info.entry.MissingNutrients...)
+	entry.Date = date
+	entry.FoodEntryId = import_util.SyntheticId(source, "entry", rec.Get(samsungColumns(foodIntakeType, "datauuid")...))
+	entry.FoodEntryDescription = info.servingDescription
+	entry.Meal = import_util.NormalizeMeal(mealTypes[rec.Get(samsungColumns(foodIntakeType, "meal_type")...)])
+	if name := rec.Get(samsungColumns(foodIntakeType, "name")...); name != "" {
+		entry.FoodEntryName = name
+	}
+
+	amount, ok, err := rec.Float(samsungColumns(foodIntakeType, "amount")...)
+	if err != nil {
+		return err
+	}
+	entry.NumberOfUnits = 1
+	if ok {
+		entry.NumberOfUnits = amount
+	}
+
+	calories, ok, err := rec.Float(samsungColumns(foodIntakeType, "calorie")...)
+	if err != nil {
+		return err
+	}
+	// food_info has nutrients per serving, the intake's calories account for the eaten amount
+	scale := entry.NumberOfUnits
+	if ok && info.entry.Calories > 0 {
+		scale = calories / info.entry.Calories
+	}
+	for _, name := range fatsecret.NutrientNames {
+		*entry.NutrientField(name) *= scale
+	}
+	if ok {
+		entry.Calories = calories
+	}
+
+	builder.AddEntry(entry)
+	return nil
+}
+
+func readWeightRecord(builder *import_util.DiaryBuilder, rec import_util.CsvRecord) error {
+	weight, ok, err := rec.Float(samsungColumns(weightType, "weight")...)
+	if err != nil || !ok {
+		return err
+	}
+
+	date, err := getRecordTime(rec, weightType)
+	if err != nil {
+		return err
+	}
+
+	builder.AddWeight(fatsecret.WeightData{Date: date, WeightKg: weight})
+	return nil
+}
+
+func getRecordTime(rec import_util.CsvRecord, dataType string) (time.Time, error) {
+	date, err := import_util.ParseDate(rec.Get(samsungColumns(dataType, "start_time")...))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+
+	offset := rec.Get(samsungColumns(dataType, "time_offset")...)
+	if offset == "" {
+		return date, nil
+	}
+	location, err := parseTimeOffset(offset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("line %d: %v", rec.Line, err)
+	}
+	return date.In(location), nil
+}
+
+func parseTimeOffset(val string) (*time.Location, error) {
+	offset := strings.TrimPrefix(val, "UTC")
+	if len(offset) != 5 || (offset[0] != '+' && offset[0] != '-') {
+		return nil, fmt.Errorf("invalid time offset %s", val)
+	}
+	hours, hoursErr := strconv.Atoi(offset[1:3])
+	minutes, minutesErr := strconv.Atoi(offset[3:5])
+	if hoursErr != nil || minutesErr != nil {
+		return nil, fmt.Errorf("invalid time offset %s", val)
+	}
+
+	seconds := hours*3600 + minutes*60
+	if offset[0] == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone(val, seconds), nil
+}
+
+func samsungColumns(dataType string, name string) []string {
+	return []string{name, dataType + "." + name}
+}
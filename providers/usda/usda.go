@@ -0,0 +1,672 @@
+package usda
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/sinks/sqlite"
+	"github.com/andre487/data-migrators/transforms"
+	"github.com/andre487/data-migrators/utils/import_util"
+	"github.com/andre487/data-migrators/utils/storage"
+)
+
+const loadBatchSize = 10000
+
+var migrations = []string{
+	`CREATE TABLE nutrients (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		unit TEXT NOT NULL,
+		rank REAL NOT NULL
+	);
+	CREATE TABLE foods (
+		fdc_id INTEGER PRIMARY KEY,
+		data_type TEXT NOT NULL,
+		description TEXT NOT NULL,
+		brand TEXT NOT NULL,
+		gtin_upc TEXT NOT NULL,
+		ingredients TEXT NOT NULL,
+		serving_grams REAL NOT NULL
+	);
+	CREATE INDEX foods_gtin_upc ON foods (gtin_upc) WHERE gtin_upc != '';
+	CREATE VIRTUAL TABLE food_names USING fts5 (
+		description, brand, content = 'foods', content_rowid = 'fdc_id', tokenize = 'porter unicode61'
+	);
+	CREATE TABLE food_nutrients (
+		fdc_id INTEGER NOT NULL,
+		nutrient_id INTEGER NOT NULL,
+		amount REAL NOT NULL,
+		PRIMARY KEY (fdc_id, nutrient_id)
+	) WITHOUT ROWID;
+	CREATE TABLE releases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		loaded_at TEXT NOT NULL,
+		foods_count INTEGER NOT NULL
+	);`,
+}
+
+const upsertFoodQuery = `INSERT INTO foods (fdc_id, data_type, description, brand, gtin_upc, ingredients, serving_grams)
+	VALUES (?, ?, ?, '', '', '', 0)
+	ON CONFLICT (fdc_id) DO UPDATE SET
+		data_type = excluded.data_type,
+		description = excluded.description`
+
+const selectFoodQuery = `SELECT fdc_id, data_type, description, brand, gtin_upc, ingredients, serving_grams FROM foods`
+
+// Sample and acquisition records are lab details of the other foods, they only clutter the name search
+var loadedDataTypes = map[string]bool{
+	"foundation_food":   true,
+	"sr_legacy_food":    true,
+	"survey_fndds_food": true,
+	"branded_food":      true,
+}
+
+type fdcNutrient struct {
+	Nutrient string
+	Ids      []int64
+	Scale    float64
+}
+
+// Amounts are per 100 g in the nutrient's unit, the first present id is used
+var fdcNutrients = []fdcNutrient{
+	{"Calories", []int64{1008, 2048, 2047}, 1},
+	{"Protein", []int64{1003}, 1},
+	{"Carbohydrate", []int64{1005, 1050}, 1},
+	{"Fat", []int64{1004, 1085}, 1},
+	{"Fiber", []int64{1079}, 1},
+	{"Sugar", []int64{2000, 1063}, 1},
+	{"SaturatedFat", []int64{1258}, 1},
+	{"MonounsaturatedFat", []int64{1292}, 1},
+	{"PolyunsaturatedFat", []int64{1293}, 1},
+	{"TransFat", []int64{1257}, 1},
+	{"Cholesterol", []int64{1253}, 1},
+	{"Sodium", []int64{1093}, 1},
+	{"Potassium", []int64{1092}, 1},
+	{"Calcium", []int64{1087}, 100.0 / fatsecret.DailyValueCalciumMg},
+	{"Iron", []int64{1089}, 100.0 / fatsecret.DailyValueIronMg},
+	{"VitaminC", []int64{1162}, 100.0 / fatsecret.DailyValueVitaminCMg},
+}
+
+const energyKjNutrientId = 1062
+
+var fatsecretNutrientIds = getFatsecretNutrientIds()
+
+func getFatsecretNutrientIds() map[int64]bool {
+	res := map[int64]bool{energyKjNutrientId: true}
+	for _, nutrient := range fdcNutrients {
+		for _, id := range nutrient.Ids {
+			res[id] = true
+		}
+	}
+	return res
+}
+
+var unitNames = map[string]string{
+	"G":    "g",
+	"MG":   "mg",
+	"UG":   "µg",
+	"KCAL": "kcal",
+	"KJ":   "kJ",
+}
+
+type Nutrient struct {
+	Id     int64
+	Name   string
+	Unit   string
+	Amount float64
+}
+
+type Food struct {
+	FdcId        int64
+	DataType     string
+	Description  string
+	Brand        string
+	GtinUpc      string
+	Ingredients  string
+	ServingGrams float64
+	Nutrients    []Nutrient
+}
+
+func (f *Food) FatSecretNutrients() map[string]float64 {
+	amounts := map[int64]float64{}
+	for _, nutrient := range f.Nutrients {
+		amounts[nutrient.Id] = nutrient.Amount
+	}
+
+	res := map[string]float64{}
+	for _, nutrient := range fdcNutrients {
+		for _, id := range nutrient.Ids {
+			if val, ok := amounts[id]; ok {
+				res[nutrient.Nutrient] = val * nutrient.Scale
+				break
+			}
+		}
+	}
+	if _, ok := res["Calories"]; !ok {
+		if kj, ok := amounts[energyKjNutrientId]; ok {
+			res["Calories"] = kj / 4.184
+		}
+	}
+	return res
+}
+
+func (f *Food) OtherNutrients() map[string]float64 {
+	res := map[string]float64{}
+	for _, nutrient := range f.Nutrients {
+		if !fatsecretNutrientIds[nutrient.Id] {
+			res[nutrient.DisplayName()] = nutrient.Amount
+		}
+	}
+	return res
+}
+
+func (n Nutrient) DisplayUnit() string {
+	if res, ok := unitNames[strings.ToUpper(n.Unit)]; ok {
+		return res
+	}
+	return n.Unit
+}
+
+func (n Nutrient) DisplayName() string {
+	return fmt.Sprintf("%s (%s)", n.Name, n.DisplayUnit())
+}
+
+type Index struct {
+	db        *sql.DB
+	nameCache map[string]*Food
+}
+
+func DefaultIndexPath() (string, error) {
+	usdaStorage, err := storage.New("usda")
+	if err != nil {
+		return "", fmt.Errorf("USDA: %v", err)
+	}
+	res, err := usdaStorage.GetFile("fooddata.db", 0644)
+	if err != nil {
+		return "", fmt.Errorf("USDA: %v", err)
+	}
+	return res, nil
+}
+
+func OpenIndex(dbPath string) (*Index, error) {
+	if dbPath == "" {
+		var err error
+		if dbPath, err = DefaultIndexPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("USDA: error when opening index: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := sqlite.Migrate(db, migrations); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("USDA: %v", err)
+	}
+	return &Index{db: db, nameCache: map[string]*Food{}}, nil
+}
+
+func (ix *Index) Close() error {
+	if err := ix.db.Close(); err != nil {
+		return fmt.Errorf("USDA: error when closing index: %v", err)
+	}
+	return nil
+}
+
+func (ix *Index) Load(releasePath string) (int, error) {
+	fsys, closeArchive, err := import_util.OpenArchive(releasePath)
+	if err != nil {
+		return 0, fmt.Errorf("USDA: %v", err)
+	}
+	defer func() {
+		_ = closeArchive()
+	}()
+
+	files := map[string][]string{}
+	for _, name := range []string{"nutrient.csv", "food.csv", "branded_food.csv", "food_portion.csv", "food_nutrient.csv"} {
+		if files[name], err = import_util.FindFiles(fsys, func(fileName string) bool { return fileName == name }); err != nil {
+			return 0, fmt.Errorf("USDA: %v", err)
+		}
+	}
+	if len(files["food.csv"]) == 0 || len(files["food_nutrient.csv"]) == 0 {
+		return 0, fmt.Errorf("USDA: there are no food.csv and food_nutrient.csv files in %s", releasePath)
+	}
+
+	loader := &releaseLoader{db: ix.db, foods: map[int64]bool{}}
+	defer loader.rollback()
+	for _, reader := range []struct {
+		Name string
+		Read func(rec import_util.CsvRecord) error
+	}{
+		{"nutrient.csv", loader.readNutrient},
+		{"food.csv", loader.readFood},
+		{"branded_food.csv", loader.readBrandedFood},
+		{"food_portion.csv", loader.readFoodPortion},
+		{"food_nutrient.csv", loader.readFoodNutrient},
+	} {
+		for _, filePath := range files[reader.Name] {
+			if err := loader.readFile(fsys, filePath, reader.Read); err != nil {
+				return len(loader.foods), fmt.Errorf("USDA: %v", err)
+			}
+		}
+	}
+	if err := loader.commit(); err != nil {
+		return len(loader.foods), fmt.Errorf("USDA: %v", err)
+	}
+
+	log.Printf("USDA: building the name index")
+	if _, err := ix.db.Exec("INSERT INTO food_names (food_names) VALUES ('rebuild')"); err != nil {
+		return len(loader.foods), fmt.Errorf("USDA: error when building the name index: %v", err)
+	}
+	_, err = ix.db.Exec(
+		"INSERT INTO releases (path, loaded_at, foods_count) VALUES (?, ?, ?)",
+		releasePath, time.Now().UTC().Format(time.RFC3339), len(loader.foods),
+	)
+	if err != nil {
+		return len(loader.foods), fmt.Errorf("USDA: error when saving release info: %v", err)
+	}
+	ix.nameCache = map[string]*Food{}
+	return len(loader.foods), nil
+}
+
+func (ix *Index) Count() (int, error) {
+	var res int
+	if err := ix.db.QueryRow("SELECT COUNT(*) FROM foods").Scan(&res); err != nil {
+		return 0, fmt.Errorf("USDA: error when counting foods: %v", err)
+	}
+	return res, nil
+}
+
+func (ix *Index) FindById(fdcId int64) (*Food, error) {
+	return ix.queryFood(selectFoodQuery+" WHERE fdc_id = ?", fdcId)
+}
+
+func (ix *Index) FindByBarcode(code string) (*Food, error) {
+	codes := []string{code}
+	if len(code) == 12 {
+		codes = append(codes, "0"+code)
+	} else if len(code) == 13 && code[0] == '0' {
+		codes = append(codes, code[1:])
+	}
+
+	for _, item := range codes {
+		res, err := ix.queryFood(selectFoodQuery+" WHERE gtin_upc = ? ORDER BY fdc_id DESC LIMIT 1", item)
+		if err != nil || res != nil {
+			return res, err
+		}
+	}
+	return nil, nil
+}
+
+func (ix *Index) FindByName(name string) (*Food, error) {
+	normalized := strings.Join(nameTokens(name), " ")
+	if normalized == "" {
+		return nil, nil
+	}
+	if res, ok := ix.nameCache[normalized]; ok {
+		return res, nil
+	}
+
+	found, err := ix.Search(name, 1)
+	if err != nil {
+		return nil, err
+	}
+	var res *Food
+	if len(found) > 0 {
+		res = found[0]
+	}
+	ix.nameCache[normalized] = res
+	return res, nil
+}
+
+// Search prefers exact descriptions, then reference data over branded foods, then the closest and shortest descriptions
+func (ix *Index) Search(query string, limit int) ([]*Food, error) {
+	if fdcId, err := strconv.ParseInt(strings.TrimSpace(query), 10, 64); err == nil {
+		res, err := ix.FindById(fdcId)
+		if err == nil && res == nil {
+			res, err = ix.FindByBarcode(strings.TrimSpace(query))
+		}
+		if err != nil || res == nil {
+			return nil, err
+		}
+		return []*Food{res}, nil
+	}
+
+	tokens := nameTokens(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	var match []string
+	for _, token := range tokens {
+		match = append(match, `"`+token+`"`)
+	}
+
+	rows, err := ix.db.Query(
+		`SELECT foods.fdc_id FROM food_names JOIN foods ON foods.fdc_id = food_names.rowid
+		WHERE food_names MATCH ?
+		ORDER BY lower(foods.description) = ? DESC,
+			CASE foods.data_type
+				WHEN 'foundation_food' THEN 1
+				WHEN 'sr_legacy_food' THEN 2
+				WHEN 'survey_fndds_food' THEN 3
+				ELSE 4
+			END,
+			bm25(food_names), length(foods.description), foods.fdc_id
+		LIMIT ?`,
+		strings.Join(match, " "), strings.ToLower(strings.TrimSpace(query)), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("USDA: error when searching foods: %v", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var fdcId int64
+		if err := rows.Scan(&fdcId); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("USDA: error when searching foods: %v", err)
+		}
+		ids = append(ids, fdcId)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("USDA: error when searching foods: %v", err)
+	}
+
+	var res []*Food
+	for _, fdcId := range ids {
+		food, err := ix.FindById(fdcId)
+		if err != nil {
+			return nil, err
+		}
+		if food != nil {
+			res = append(res, food)
+		}
+	}
+	return res, nil
+}
+
+func (ix *Index) Name() string {
+	return "USDA"
+}
+
+func (ix *Index) EnrichEntry(entry *fatsecret.FoodEntryData) (bool, error) {
+	var food *Food
+	var err error
+	if entry.FdcId != 0 {
+		if food, err = ix.FindById(entry.FdcId); err != nil {
+			return false, err
+		}
+	}
+	barcode := transforms.GetEntryBarcode(entry)
+	if food == nil && barcode != "" {
+		if food, err = ix.FindByBarcode(barcode); err != nil {
+			return false, err
+		}
+	}
+	if food == nil {
+		if food, err = ix.FindByName(entry.FoodEntryName); err != nil {
+			return false, err
+		}
+	}
+	if food == nil {
+		return false, nil
+	}
+
+	changed := false
+	if entry.FdcId != food.FdcId {
+		entry.FdcId = food.FdcId
+		changed = true
+	}
+
+	per100g := food.FatSecretNutrients()
+	grams := transforms.GetEntryGrams(entry, per100g, food.ServingGrams)
+	if transforms.FillMissingNutrients(entry, per100g, grams) > 0 {
+		changed = true
+	}
+	if grams > 0 {
+		for name, val := range food.OtherNutrients() {
+			if _, ok := entry.OtherNutrients[name]; ok {
+				continue
+			}
+			if entry.OtherNutrients == nil {
+				entry.OtherNutrients = map[string]float64{}
+			}
+			entry.OtherNutrients[name] = val * grams / 100
+			changed = true
+		}
+	}
+
+	if entry.Ingredients == "" && food.Ingredients != "" {
+		entry.Ingredients = food.Ingredients
+		changed = true
+	}
+	if entry.Barcode == "" && barcode != "" && food.GtinUpc != "" {
+		entry.Barcode = food.GtinUpc
+		changed = true
+	}
+	return changed, nil
+}
+
+func (ix *Index) queryFood(query string, args ...interface{}) (*Food, error) {
+	res := &Food{}
+	err := ix.db.QueryRow(query, args...).Scan(
+		&res.FdcId, &res.DataType, &res.Description, &res.Brand, &res.GtinUpc, &res.Ingredients, &res.ServingGrams,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("USDA: error when querying food: %v", err)
+	}
+
+	rows, err := ix.db.Query(
+		`SELECT nutrients.id, nutrients.name, nutrients.unit, food_nutrients.amount
+		FROM food_nutrients JOIN nutrients ON nutrients.id = food_nutrients.nutrient_id
+		WHERE food_nutrients.fdc_id = ?
+		ORDER BY nutrients.rank, nutrients.id`,
+		res.FdcId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("USDA: error when querying food %d nutrients: %v", res.FdcId, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		nutrient := Nutrient{}
+		if err := rows.Scan(&nutrient.Id, &nutrient.Name, &nutrient.Unit, &nutrient.Amount); err != nil {
+			return nil, fmt.Errorf("USDA: error when querying food %d nutrients: %v", res.FdcId, err)
+		}
+		res.Nutrients = append(res.Nutrients, nutrient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("USDA: error when querying food %d nutrients: %v", res.FdcId, err)
+	}
+	return res, nil
+}
+
+type releaseLoader struct {
+	db         *sql.DB
+	tx         *sql.Tx
+	statements int
+	foods      map[int64]bool
+}
+
+func (l *releaseLoader) readFile(fsys fs.FS, filePath string, read func(rec import_util.CsvRecord) error) error {
+	rows := 0
+	err := import_util.ReadCsvFile(fsys, filePath, ',', func(rec import_util.CsvRecord) error {
+		rows++
+		if rows%(loadBatchSize*100) == 0 {
+			log.Printf("USDA: read %d rows of %s", rows, filePath)
+		}
+		return read(rec)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("USDA: read %d rows of %s", rows, filePath)
+	return nil
+}
+
+func (l *releaseLoader) readNutrient(rec import_util.CsvRecord) error {
+	id, err := parseId(rec, "id")
+	if err != nil {
+		return err
+	}
+	rank, ok, err := rec.Float("rank")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		rank = 999999
+	}
+	return l.exec(
+		`INSERT INTO nutrients (id, name, unit, rank) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, unit = excluded.unit, rank = excluded.rank`,
+		id, rec.Get("name"), rec.Get("unit_name"), rank,
+	)
+}
+
+func (l *releaseLoader) readFood(rec import_util.CsvRecord) error {
+	dataType := rec.Get("data_type")
+	if !loadedDataTypes[dataType] {
+		return nil
+	}
+	fdcId, err := parseId(rec, "fdc_id")
+	if err != nil {
+		return err
+	}
+
+	l.foods[fdcId] = true
+	if err := l.exec(upsertFoodQuery, fdcId, dataType, rec.Get("description")); err != nil {
+		return err
+	}
+	return l.exec("DELETE FROM food_nutrients WHERE fdc_id = ?", fdcId)
+}
+
+func (l *releaseLoader) readBrandedFood(rec import_util.CsvRecord) error {
+	fdcId, err := parseId(rec, "fdc_id")
+	if err != nil || !l.foods[fdcId] {
+		return err
+	}
+
+	var servingGrams float64
+	switch strings.ToLower(rec.Get("serving_size_unit")) {
+	case "g", "grm":
+		if servingGrams, _, err = rec.Float("serving_size"); err != nil {
+			return err
+		}
+	}
+	brand := rec.Get("brand_name")
+	if brand == "" {
+		brand = rec.Get("brand_owner")
+	}
+	return l.exec(
+		"UPDATE foods SET brand = ?, gtin_upc = ?, ingredients = ?, serving_grams = ? WHERE fdc_id = ?",
+		brand, rec.Get("gtin_upc"), rec.Get("ingredients"), servingGrams, fdcId,
+	)
+}
+
+// The first portion of a food becomes its serving, the branded foods have theirs already
+func (l *releaseLoader) readFoodPortion(rec import_util.CsvRecord) error {
+	fdcId, err := parseId(rec, "fdc_id")
+	if err != nil || !l.foods[fdcId] {
+		return err
+	}
+	grams, ok, err := rec.Float("gram_weight")
+	if err != nil || !ok || grams <= 0 {
+		return err
+	}
+	amount, ok, err := rec.Float("amount")
+	if err != nil {
+		return err
+	}
+	if ok && amount > 0 {
+		grams /= amount
+	}
+	return l.exec("UPDATE foods SET serving_grams = ? WHERE fdc_id = ? AND serving_grams = 0", grams, fdcId)
+}
+
+func (l *releaseLoader) readFoodNutrient(rec import_util.CsvRecord) error {
+	fdcId, err := parseId(rec, "fdc_id")
+	if err != nil || !l.foods[fdcId] {
+		return err
+	}
+	nutrientId, err := parseId(rec, "nutrient_id")
+	if err != nil {
+		return err
+	}
+	amount, ok, err := rec.Float("amount")
+	if err != nil || !ok {
+		return err
+	}
+	return l.exec(
+		"INSERT OR REPLACE INTO food_nutrients (fdc_id, nutrient_id, amount) VALUES (?, ?, ?)",
+		fdcId, nutrientId, amount,
+	)
+}
+
+func (l *releaseLoader) exec(query string, args ...interface{}) error {
+	if l.tx == nil {
+		var err error
+		if l.tx, err = l.db.Begin(); err != nil {
+			return fmt.Errorf("error when starting transaction: %v", err)
+		}
+	}
+	if _, err := l.tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("error when saving release data: %v", err)
+	}
+
+	l.statements++
+	if l.statements%loadBatchSize == 0 {
+		return l.commit()
+	}
+	return nil
+}
+
+func (l *releaseLoader) commit() error {
+	if l.tx == nil {
+		return nil
+	}
+	tx := l.tx
+	l.tx = nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error when committing release data: %v", err)
+	}
+	return nil
+}
+
+func (l *releaseLoader) rollback() {
+	if l.tx != nil {
+		if err := l.tx.Rollback(); err != nil {
+			log.Printf("WARN: USDA: error when rolling back transaction: %v", err)
+		}
+		l.tx = nil
+	}
+}
+
+func parseId(rec import_util.CsvRecord, column string) (int64, error) {
+	res, err := strconv.ParseInt(rec.Get(column), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid %s %s: %v", rec.Line, column, rec.Get(column), err)
+	}
+	return res, nil
+}
+
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,492 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+var migrations = []string{
+	`CREATE TABLE sync_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		from_date TEXT NOT NULL,
+		to_date TEXT NOT NULL,
+		started_at TEXT NOT NULL,
+		finished_at TEXT,
+		status TEXT NOT NULL,
+		error TEXT,
+		days_count INTEGER NOT NULL DEFAULT 0,
+		entries_count INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE days (
+		date_int INTEGER PRIMARY KEY,
+		date TEXT NOT NULL,
+		calories REAL NOT NULL,
+		carbohydrate REAL NOT NULL,
+		fat REAL NOT NULL,
+		protein REAL NOT NULL,
+		sync_run_id INTEGER NOT NULL REFERENCES sync_runs (id),
+		updated_at TEXT NOT NULL
+	);
+	CREATE TABLE foods (
+		food_id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		sync_run_id INTEGER NOT NULL REFERENCES sync_runs (id),
+		updated_at TEXT NOT NULL
+	);
+	CREATE TABLE food_entries (
+		food_entry_id INTEGER PRIMARY KEY,
+		date_int INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		food_id INTEGER NOT NULL,
+		serving_id INTEGER NOT NULL,
+		food_entry_name TEXT NOT NULL,
+		food_entry_description TEXT NOT NULL,
+		number_of_units REAL NOT NULL,
+		meal TEXT NOT NULL,
+		calories REAL NOT NULL,
+		protein REAL NOT NULL,
+		carbohydrate REAL NOT NULL,
+		fat REAL NOT NULL,
+		fiber REAL NOT NULL,
+		sugar REAL NOT NULL,
+		saturated_fat REAL NOT NULL,
+		monounsaturated_fat REAL NOT NULL,
+		polyunsaturated_fat REAL NOT NULL,
+		trans_fat REAL NOT NULL,
+		cholesterol REAL NOT NULL,
+		sodium REAL NOT NULL,
+		potassium REAL NOT NULL,
+		calcium REAL NOT NULL,
+		iron REAL NOT NULL,
+		vitamin_a REAL NOT NULL,
+		vitamin_c REAL NOT NULL,
+		sync_run_id INTEGER NOT NULL REFERENCES sync_runs (id),
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX food_entries_date_int ON food_entries (date_int);
+	CREATE INDEX food_entries_food_id ON food_entries (food_id);`,
+	`CREATE TABLE source_days (
+		source TEXT NOT NULL,
+		date_int INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		calories REAL NOT NULL,
+		carbohydrate REAL NOT NULL,
+		fat REAL NOT NULL,
+		protein REAL NOT NULL,
+		sync_run_id INTEGER NOT NULL REFERENCES sync_runs (id),
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (source, date_int)
+	);
+	INSERT INTO source_days
+		SELECT sync_runs.source, days.date_int, days.date, days.calories, days.carbohydrate, days.fat, days.protein,
+			days.sync_run_id, days.updated_at
+		FROM days JOIN sync_runs ON sync_runs.id = days.sync_run_id;
+	DROP TABLE days;
+	ALTER TABLE source_days RENAME TO days;
+	CREATE TABLE weights (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		date_int INTEGER NOT NULL,
+		measured_at TEXT NOT NULL,
+		weight_kg REAL NOT NULL,
+		comment TEXT,
+		sync_run_id INTEGER NOT NULL REFERENCES sync_runs (id),
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX weights_source_date_int ON weights (source, date_int);
+	CREATE TABLE exercises (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		date_int INTEGER NOT NULL,
+		started_at TEXT NOT NULL,
+		name TEXT NOT NULL,
+		duration_minutes REAL NOT NULL,
+		calories REAL NOT NULL,
+		steps INTEGER,
+		distance_km REAL,
+		sync_run_id INTEGER NOT NULL REFERENCES sync_runs (id),
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX exercises_source_date_int ON exercises (source, date_int);
+	ALTER TABLE sync_runs ADD COLUMN weights_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE sync_runs ADD COLUMN exercises_count INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE food_entries ADD COLUMN other_nutrients TEXT;`,
+	`ALTER TABLE food_entries ADD COLUMN barcode TEXT;
+	ALTER TABLE food_entries ADD COLUMN nova_group INTEGER;
+	ALTER TABLE food_entries ADD COLUMN ingredients TEXT;`,
+	`ALTER TABLE food_entries ADD COLUMN fdc_id INTEGER;`,
+}
+
+// Sources keep their own days, so importing another app doesn't overwrite the totals of the synced one
+const upsertDayQuery = `INSERT INTO days (source, date_int, date, calories, carbohydrate, fat, protein, sync_run_id, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (source, date_int) DO UPDATE SET
+		date = excluded.date,
+		calories = excluded.calories,
+		carbohydrate = excluded.carbohydrate,
+		fat = excluded.fat,
+		protein = excluded.protein,
+		sync_run_id = excluded.sync_run_id,
+		updated_at = excluded.updated_at`
+
+const upsertFoodQuery = `INSERT INTO foods (food_id, name, sync_run_id, updated_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (food_id) DO UPDATE SET
+		sync_run_id = excluded.sync_run_id,
+		updated_at = excluded.updated_at`
+
+// Diaries have no food names, so a food is named by its most used entry name instead of the last one
+const updateFoodNamesQuery = `UPDATE foods SET name = (
+		SELECT food_entry_name FROM food_entries WHERE food_entries.food_id = foods.food_id
+		GROUP BY food_entry_name ORDER BY COUNT(*) DESC, food_entry_name LIMIT 1
+	)
+	WHERE sync_run_id = ? AND EXISTS (SELECT 1 FROM food_entries WHERE food_entries.food_id = foods.food_id)`
+
+const upsertFoodEntryQuery = `INSERT INTO food_entries (
+		food_entry_id, date_int, date, food_id, serving_id, food_entry_name, food_entry_description,
+		number_of_units, meal, calories, protein, carbohydrate, fat, fiber, sugar, saturated_fat,
+		monounsaturated_fat, polyunsaturated_fat, trans_fat, cholesterol, sodium, potassium, calcium,
+		iron, vitamin_a, vitamin_c, other_nutrients, barcode, nova_group, ingredients, fdc_id,
+		sync_run_id, updated_at
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (food_entry_id) DO UPDATE SET
+		date_int = excluded.date_int,
+		date = excluded.date,
+		food_id = excluded.food_id,
+		serving_id = excluded.serving_id,
+		food_entry_name = excluded.food_entry_name,
+		food_entry_description = excluded.food_entry_description,
+		number_of_units = excluded.number_of_units,
+		meal = excluded.meal,
+		calories = excluded.calories,
+		protein = excluded.protein,
+		carbohydrate = excluded.carbohydrate,
+		fat = excluded.fat,
+		fiber = excluded.fiber,
+		sugar = excluded.sugar,
+		saturated_fat = excluded.saturated_fat,
+		monounsaturated_fat = excluded.monounsaturated_fat,
+		polyunsaturated_fat = excluded.polyunsaturated_fat,
+		trans_fat = excluded.trans_fat,
+		cholesterol = excluded.cholesterol,
+		sodium = excluded.sodium,
+		potassium = excluded.potassium,
+		calcium = excluded.calcium,
+		iron = excluded.iron,
+		vitamin_a = excluded.vitamin_a,
+		vitamin_c = excluded.vitamin_c,
+		other_nutrients = excluded.other_nutrients,
+		barcode = excluded.barcode,
+		nova_group = excluded.nova_group,
+		ingredients = excluded.ingredients,
+		fdc_id = excluded.fdc_id,
+		sync_run_id = excluded.sync_run_id,
+		updated_at = excluded.updated_at`
+
+const insertWeightQuery = `INSERT INTO weights (source, date_int, measured_at, weight_kg, comment, sync_run_id, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+const insertExerciseQuery = `INSERT INTO exercises (
+		source, date_int, started_at, name, duration_minutes, calories, steps, distance_km, sync_run_id, updated_at
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+type Sink struct {
+	db               *sql.DB
+	tx               *sql.Tx
+	runId            int64
+	source           string
+	daysCount        int64
+	entriesCount     int64
+	weightsCount     int64
+	exercisesCount   int64
+	dateInts         []int64
+	weightDateInts   []int64
+	exerciseDateInts []int64
+}
+
+func Open(dbPath string) (*Sink, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("SQLite: error when opening database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("SQLite: error when enabling foreign keys: %v", err)
+	}
+
+	if err := Migrate(db, migrations); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Sink{db: db}, nil
+}
+
+func Migrate(db *sql.DB, migrations []string) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("SQLite: error when getting schema version: %v", err)
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("SQLite: error when starting migration: %v", err)
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			rollback(tx)
+			return fmt.Errorf("SQLite: error when applying migration %d: %v", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			rollback(tx)
+			return fmt.Errorf("SQLite: error when setting schema version: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("SQLite: error when committing migration %d: %v", version+1, err)
+		}
+		log.Printf("SQLite: applied migration %d\n", version+1)
+	}
+	return nil
+}
+
+func (s *Sink) BeginRun(source string, fromDate time.Time, toDate time.Time) error {
+	res, err := s.db.Exec(
+		"INSERT INTO sync_runs (source, from_date, to_date, started_at, status) VALUES (?, ?, ?, ?, ?)",
+		source, formatDate(fromDate), formatDate(toDate), formatTime(time.Now()), "running",
+	)
+	if err != nil {
+		return fmt.Errorf("SQLite: error when creating sync run: %v", err)
+	}
+	if s.runId, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("SQLite: error when creating sync run: %v", err)
+	}
+
+	s.source = source
+	s.daysCount = 0
+	s.entriesCount = 0
+	s.weightsCount = 0
+	s.exercisesCount = 0
+	s.dateInts = nil
+	s.weightDateInts = nil
+	s.exerciseDateInts = nil
+	if s.tx, err = s.db.Begin(); err != nil {
+		return fmt.Errorf("SQLite: error when starting transaction: %v", err)
+	}
+	return nil
+}
+
+func (s *Sink) ConsumeDay(day fatsecret.FoodEntryDayData) error {
+	if s.tx == nil {
+		return fmt.Errorf("SQLite: sync run is not started")
+	}
+
+	_, err := s.tx.Exec(
+		upsertDayQuery,
+		s.source, day.DateInt, formatDate(day.Date), day.Calories, day.Carbohydrate, day.Fat, day.Protein,
+		s.runId, formatTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLite: error when saving day %s: %v", formatDate(day.Date), err)
+	}
+
+	s.daysCount++
+	s.dateInts = append(s.dateInts, day.DateInt)
+	return nil
+}
+
+func (s *Sink) ConsumeEntry(entry fatsecret.FoodEntryData) error {
+	if s.tx == nil {
+		return fmt.Errorf("SQLite: sync run is not started")
+	}
+
+	var otherNutrients interface{}
+	if len(entry.OtherNutrients) > 0 {
+		data, err := json.Marshal(entry.OtherNutrients)
+		if err != nil {
+			return fmt.Errorf("SQLite: error when encoding other nutrients of food entry %d: %v", entry.FoodEntryId, err)
+		}
+		otherNutrients = string(data)
+	}
+
+	now := formatTime(time.Now())
+	if _, err := s.tx.Exec(upsertFoodQuery, entry.FoodId, entry.FoodEntryName, s.runId, now); err != nil {
+		return fmt.Errorf("SQLite: error when saving food %d: %v", entry.FoodId, err)
+	}
+
+	_, err := s.tx.Exec(
+		upsertFoodEntryQuery,
+		entry.FoodEntryId, entry.DateInt, formatDate(entry.Date), entry.FoodId, entry.ServingId,
+		entry.FoodEntryName, entry.FoodEntryDescription, entry.NumberOfUnits, entry.Meal,
+		entry.Calories, entry.Protein, entry.Carbohydrate, entry.Fat, entry.Fiber, entry.Sugar,
+		entry.SaturatedFat, entry.MonounsaturatedFat, entry.PolyunsaturatedFat, entry.TransFat,
+		entry.Cholesterol, entry.Sodium, entry.Potassium, entry.Calcium, entry.Iron,
+		entry.VitaminA, entry.VitaminC, otherNutrients, nullString(entry.Barcode), nullInt(int64(entry.NovaGroup)),
+		nullString(entry.Ingredients), nullInt(entry.FdcId), s.runId, now,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLite: error when saving food entry %d: %v", entry.FoodEntryId, err)
+	}
+
+	s.entriesCount++
+	return nil
+}
+
+func (s *Sink) ConsumeWeight(weight fatsecret.WeightData) error {
+	if s.tx == nil {
+		return fmt.Errorf("SQLite: sync run is not started")
+	}
+
+	_, err := s.tx.Exec(
+		insertWeightQuery,
+		s.source, weight.DateInt, formatTime(weight.Date), weight.WeightKg, nullString(weight.Comment),
+		s.runId, formatTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLite: error when saving weight of %s: %v", formatDate(weight.Date), err)
+	}
+
+	s.weightsCount++
+	s.weightDateInts = append(s.weightDateInts, weight.DateInt)
+	return nil
+}
+
+func (s *Sink) ConsumeExercise(exercise fatsecret.ExerciseData) error {
+	if s.tx == nil {
+		return fmt.Errorf("SQLite: sync run is not started")
+	}
+
+	var distanceKm interface{}
+	if exercise.DistanceKm > 0 {
+		distanceKm = exercise.DistanceKm
+	}
+	_, err := s.tx.Exec(
+		insertExerciseQuery,
+		s.source, exercise.DateInt, formatTime(exercise.Date), exercise.Name, exercise.DurationMinutes,
+		exercise.Calories, nullInt(exercise.Steps), distanceKm, s.runId, formatTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLite: error when saving exercise %s of %s: %v", exercise.Name, formatDate(exercise.Date), err)
+	}
+
+	s.exercisesCount++
+	s.exerciseDateInts = append(s.exerciseDateInts, exercise.DateInt)
+	return nil
+}
+
+func (s *Sink) FinishRun(runErr error) error {
+	if s.tx == nil {
+		return fmt.Errorf("SQLite: sync run is not started")
+	}
+	tx := s.tx
+	s.tx = nil
+
+	status := "success"
+	var errMsg interface{}
+	if runErr == nil {
+		runErr = s.removeStaleEntries(tx)
+	}
+	if runErr == nil {
+		if _, err := tx.Exec(updateFoodNamesQuery, s.runId); err != nil {
+			runErr = fmt.Errorf("SQLite: error when updating food names: %v", err)
+		}
+	}
+	if runErr == nil {
+		if err := tx.Commit(); err != nil {
+			runErr = fmt.Errorf("SQLite: error when committing sync run: %v", err)
+		}
+	} else {
+		rollback(tx)
+	}
+	if runErr != nil {
+		status = "failed"
+		errMsg = runErr.Error()
+	}
+
+	_, err := s.db.Exec(
+		`UPDATE sync_runs SET finished_at = ?, status = ?, error = ?, days_count = ?, entries_count = ?,
+			weights_count = ?, exercises_count = ?
+		WHERE id = ?`,
+		formatTime(time.Now()), status, errMsg, s.daysCount, s.entriesCount,
+		s.weightsCount, s.exercisesCount, s.runId,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLite: error when finishing sync run: %v", err)
+	}
+	return runErr
+}
+
+func (s *Sink) Close() error {
+	if s.tx != nil {
+		rollback(s.tx)
+		s.tx = nil
+	}
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("SQLite: error when closing database: %v", err)
+	}
+	return nil
+}
+
+// Rows of the synced dates that the run hasn't written are stale, but only the ones of the same source:
+// other sources of the same dates are kept
+func (s *Sink) removeStaleEntries(tx *sql.Tx) error {
+	tables := []struct {
+		Name     string
+		DateInts []int64
+	}{
+		{"food_entries", s.dateInts},
+		{"weights", s.weightDateInts},
+		{"exercises", s.exerciseDateInts},
+	}
+	for _, table := range tables {
+		query := fmt.Sprintf(
+			"DELETE FROM %s WHERE date_int = ? AND sync_run_id != ? AND sync_run_id IN (SELECT id FROM sync_runs WHERE source = ?)",
+			table.Name,
+		)
+		for _, dateInt := range table.DateInts {
+			if _, err := tx.Exec(query, dateInt, s.runId, s.source); err != nil {
+				return fmt.Errorf("SQLite: error when removing stale %s: %v", strings.ReplaceAll(table.Name, "_", " "), err)
+			}
+		}
+	}
+	return nil
+}
+
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil {
+		log.Printf("WARN: SQLite: error when rolling back transaction: %v", err)
+	}
+}
+
+func nullString(val string) interface{} {
+	if val == "" {
+		return nil
+	}
+	return val
+}
+
+func nullInt(val int64) interface{} {
+	if val == 0 {
+		return nil
+	}
+	return val
+}
+
+func formatDate(dt time.Time) string {
+	return dt.Format(time.DateOnly)
+}
+
+func formatTime(dt time.Time) string {
+	return dt.UTC().Format(time.RFC3339)
+}
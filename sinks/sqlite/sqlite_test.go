@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+var testDate = time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+func syncTestDiary(t *testing.T, sink *Sink, source string, calories float64, entryIds ...int64) {
+	t.Helper()
+	if err := sink.BeginRun(source, testDate, testDate); err != nil {
+		t.Fatal(err)
+	}
+
+	var consumer fatsecret.DiaryConsumer = sink
+	data := &fatsecret.DiaryData{
+		AggregatedDayData: []fatsecret.FoodEntryDayData{{DateInt: 19787, Date: testDate, Calories: calories}},
+		Weights:           []fatsecret.WeightData{{DateInt: 19787, Date: testDate.Add(7 * time.Hour), WeightKg: 72.4}},
+		Exercises:         []fatsecret.ExerciseData{{DateInt: 19787, Date: testDate, Name: "Running", DurationMinutes: 30}},
+	}
+	for _, id := range entryIds {
+		data.DiaryData = append(data.DiaryData, fatsecret.FoodEntryData{
+			DateInt: 19787, Date: testDate, FoodId: id, FoodEntryId: id, FoodEntryName: source, Calories: calories,
+		})
+	}
+
+	if err := sink.FinishRun(data.Stream(consumer)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func queryCount(t *testing.T, sink *Sink, query string, args ...interface{}) int {
+	t.Helper()
+	var res int
+	if err := sink.db.QueryRow(query, args...).Scan(&res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestSinkKeepsSources(t *testing.T) {
+	sink, err := Open(path.Join(t.TempDir(), "diary.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	syncTestDiary(t, sink, "fatsecret", 1800, 1, 2)
+	syncTestDiary(t, sink, "cronometer", 2100, 3)
+	// Re-syncing a source replaces its own rows of the date only
+	syncTestDiary(t, sink, "cronometer", 2200, 4)
+
+	counts := map[string]int{
+		"SELECT COUNT(*) FROM food_entries WHERE food_entry_name = 'fatsecret'":                                 2,
+		"SELECT COUNT(*) FROM food_entries WHERE food_entry_name = 'cronometer'":                                1,
+		"SELECT COUNT(*) FROM food_entries WHERE food_entry_id = 4":                                             1,
+		"SELECT COUNT(*) FROM days":                                                                             2,
+		"SELECT COUNT(*) FROM weights":                                                                          2,
+		"SELECT COUNT(*) FROM exercises WHERE source = 'cronometer'":                                            1,
+		"SELECT COUNT(*) FROM sync_runs WHERE status = 'success' AND weights_count = 1 AND exercises_count = 1": 3,
+	}
+	for query, expected := range counts {
+		if res := queryCount(t, sink, query); res != expected {
+			t.Errorf("%s: expected %d, got %d", query, expected, res)
+		}
+	}
+
+	for source, expected := range map[string]float64{"fatsecret": 1800, "cronometer": 2200} {
+		var calories float64
+		if err := sink.db.QueryRow("SELECT calories FROM days WHERE source = ? AND date_int = 19787", source).Scan(&calories); err != nil {
+			t.Fatal(err)
+		}
+		if calories != expected {
+			t.Errorf("%s: expected %v calories, got %v", source, expected, calories)
+		}
+	}
+}
+
+func TestMigrateKeepsDays(t *testing.T) {
+	db, err := sql.Open("sqlite", path.Join(t.TempDir(), "diary.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// The schema before the days got their source
+	if err := Migrate(db, migrations[:1]); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"INSERT INTO sync_runs (source, from_date, to_date, started_at, status) VALUES ('fatsecret', '', '', '', 'success')",
+		"INSERT INTO days VALUES (19787, '2024-03-05', 1800, 200, 60, 90, 1, '')",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(db, migrations); err != nil {
+		t.Fatal(err)
+	}
+	var res int
+	if err := db.QueryRow("SELECT COUNT(*) FROM days WHERE source = 'fatsecret' AND calories = 1800").Scan(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res != 1 {
+		t.Errorf("expected a migrated day, got %d", res)
+	}
+}
@@ -0,0 +1,61 @@
+package transforms
+
+import (
+	"log"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+type EntryEnricher interface {
+	Name() string
+	EnrichEntry(entry *fatsecret.FoodEntryData) (bool, error)
+}
+
+type EnrichConsumer struct {
+	consumer  fatsecret.DiaryConsumer
+	enrichers []EntryEnricher
+	counts    []int
+	total     int
+}
+
+func NewEnrichConsumer(consumer fatsecret.DiaryConsumer, enrichers ...EntryEnricher) *EnrichConsumer {
+	return &EnrichConsumer{consumer: consumer, enrichers: enrichers, counts: make([]int, len(enrichers))}
+}
+
+func (c *EnrichConsumer) ConsumeDay(day fatsecret.FoodEntryDayData) error {
+	return c.consumer.ConsumeDay(day)
+}
+
+func (c *EnrichConsumer) ConsumeEntry(entry fatsecret.FoodEntryData) error {
+	c.total++
+	for i, enricher := range c.enrichers {
+		ok, err := enricher.EnrichEntry(&entry)
+		if err != nil {
+			return err
+		}
+		if ok {
+			c.counts[i]++
+		}
+	}
+	return c.consumer.ConsumeEntry(entry)
+}
+
+func (c *EnrichConsumer) ConsumeWeight(weight fatsecret.WeightData) error {
+	if consumer, ok := c.consumer.(fatsecret.WeightConsumer); ok {
+		return consumer.ConsumeWeight(weight)
+	}
+	return nil
+}
+
+func (c *EnrichConsumer) ConsumeExercise(exercise fatsecret.ExerciseData) error {
+	if consumer, ok := c.consumer.(fatsecret.ExerciseConsumer); ok {
+		return consumer.ConsumeExercise(exercise)
+	}
+	return nil
+}
+
+func (c *EnrichConsumer) LogStats() {
+	for i, enricher := range c.enrichers {
+		log.Printf("%s: enriched %d of %d food entries", enricher.Name(), c.counts[i], c.total)
+	}
+}
@@ -0,0 +1,51 @@
+package transforms
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+)
+
+var barcodePattern = regexp.MustCompile(`^\d{8,14}$`)
+
+func GetEntryBarcode(entry *fatsecret.FoodEntryData) string {
+	for _, val := range []string{entry.Barcode, entry.FoodEntryName, entry.FoodEntryDescription} {
+		if val = strings.TrimSpace(val); barcodePattern.MatchString(val) {
+			return val
+		}
+	}
+	return ""
+}
+
+// Reference values are per 100 g, so the eaten amount is estimated by calories or by the serving size
+func GetEntryGrams(entry *fatsecret.FoodEntryData, per100g map[string]float64, servingGrams float64) float64 {
+	if entry.Calories > 0 && per100g["Calories"] > 0 && !slices.Contains(entry.MissingNutrients, "Calories") {
+		return entry.Calories * 100 / per100g["Calories"]
+	}
+	if servingGrams > 0 && entry.NumberOfUnits > 0 {
+		return servingGrams * entry.NumberOfUnits
+	}
+	return 0
+}
+
+func FillMissingNutrients(entry *fatsecret.FoodEntryData, per100g map[string]float64, grams float64) int {
+	if grams <= 0 || len(entry.MissingNutrients) == 0 {
+		return 0
+	}
+
+	filled := 0
+	var missing []string
+	for _, name := range entry.MissingNutrients {
+		val, ok := per100g[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		*entry.NutrientField(name) = val * grams / 100
+		filled++
+	}
+	entry.MissingNutrients = missing
+	return filled
+}
@@ -0,0 +1,395 @@
+package fit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const TimestampField = 253
+
+const (
+	MesgFileId      = 0
+	MesgSport       = 12
+	MesgSession     = 18
+	MesgWeightScale = 30
+	MesgActivity    = 34
+)
+
+const (
+	baseEnum    = 0x00
+	baseSint8   = 0x01
+	baseUint8   = 0x02
+	baseSint16  = 0x03
+	baseUint16  = 0x04
+	baseSint32  = 0x05
+	baseUint32  = 0x06
+	baseString  = 0x07
+	baseFloat32 = 0x08
+	baseFloat64 = 0x09
+	baseUint8z  = 0x0a
+	baseUint16z = 0x0b
+	baseUint32z = 0x0c
+	baseByte    = 0x0d
+	baseSint64  = 0x0e
+	baseUint64  = 0x0f
+	baseUint64z = 0x10
+)
+
+var baseTypeSizes = map[byte]int{
+	baseEnum: 1, baseSint8: 1, baseUint8: 1, baseSint16: 2, baseUint16: 2, baseSint32: 4, baseUint32: 4,
+	baseString: 1, baseFloat32: 4, baseFloat64: 8, baseUint8z: 1, baseUint16z: 2, baseUint32z: 4,
+	baseByte: 1, baseSint64: 8, baseUint64: 8, baseUint64z: 8,
+}
+
+var crcTable = [16]uint16{
+	0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+	0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+var epoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type Message struct {
+	Num    uint16
+	Fields map[uint8]Field
+}
+
+type Field struct {
+	BaseType byte
+	Data     []byte
+	order    binary.ByteOrder
+}
+
+type definition struct {
+	num     uint16
+	order   binary.ByteOrder
+	fields  []fieldDefinition
+	devSize int
+}
+
+type fieldDefinition struct {
+	num      uint8
+	size     int
+	baseType byte
+}
+
+type decoder struct {
+	r             *bufio.Reader
+	crc           uint16
+	read          uint32
+	defs          [16]*definition
+	lastTimestamp uint32
+}
+
+func Decode(r io.Reader, fn func(msg Message) error) error {
+	d := &decoder{r: bufio.NewReader(r)}
+	for files := 0; ; files++ {
+		if _, err := d.r.Peek(1); errors.Is(err, io.EOF) && files > 0 {
+			return nil
+		}
+		if err := d.decodeFile(fn); err != nil {
+			return err
+		}
+	}
+}
+
+func Time(val uint32) time.Time {
+	return epoch.Add(time.Duration(val) * time.Second)
+}
+
+func (m Message) Uint(num uint8) (uint64, bool) {
+	field, ok := m.Fields[num]
+	if !ok || len(field.Data) < baseTypeSizes[field.BaseType] {
+		return 0, false
+	}
+
+	var res uint64
+	var invalid uint64
+	switch field.BaseType {
+	case baseEnum, baseUint8, baseByte:
+		res, invalid = uint64(field.Data[0]), math.MaxUint8
+	case baseUint8z:
+		res, invalid = uint64(field.Data[0]), 0
+	case baseUint16:
+		res, invalid = uint64(field.order.Uint16(field.Data)), math.MaxUint16
+	case baseUint16z:
+		res, invalid = uint64(field.order.Uint16(field.Data)), 0
+	case baseUint32:
+		res, invalid = uint64(field.order.Uint32(field.Data)), math.MaxUint32
+	case baseUint32z:
+		res, invalid = uint64(field.order.Uint32(field.Data)), 0
+	case baseUint64:
+		res, invalid = field.order.Uint64(field.Data), math.MaxUint64
+	case baseUint64z:
+		res, invalid = field.order.Uint64(field.Data), 0
+	case baseSint8, baseSint16, baseSint32, baseSint64:
+		val, ok := m.Int(num)
+		return uint64(val), ok && val >= 0
+	default:
+		return 0, false
+	}
+	return res, res != invalid
+}
+
+func (m Message) Int(num uint8) (int64, bool) {
+	field, ok := m.Fields[num]
+	if !ok || len(field.Data) < baseTypeSizes[field.BaseType] {
+		return 0, false
+	}
+
+	switch field.BaseType {
+	case baseSint8:
+		res := int8(field.Data[0])
+		return int64(res), res != math.MaxInt8
+	case baseSint16:
+		res := int16(field.order.Uint16(field.Data))
+		return int64(res), res != math.MaxInt16
+	case baseSint32:
+		res := int32(field.order.Uint32(field.Data))
+		return int64(res), res != math.MaxInt32
+	case baseSint64:
+		res := int64(field.order.Uint64(field.Data))
+		return res, res != math.MaxInt64
+	case baseEnum, baseUint8, baseUint8z, baseByte, baseUint16, baseUint16z,
+		baseUint32, baseUint32z, baseUint64, baseUint64z:
+		val, ok := m.Uint(num)
+		return int64(val), ok && val <= math.MaxInt64
+	default:
+		return 0, false
+	}
+}
+
+func (m Message) Float(num uint8) (float64, bool) {
+	field, ok := m.Fields[num]
+	if !ok || len(field.Data) < baseTypeSizes[field.BaseType] {
+		return 0, false
+	}
+
+	switch field.BaseType {
+	case baseFloat32:
+		bits := field.order.Uint32(field.Data)
+		return float64(math.Float32frombits(bits)), bits != math.MaxUint32
+	case baseFloat64:
+		bits := field.order.Uint64(field.Data)
+		return math.Float64frombits(bits), bits != math.MaxUint64
+	default:
+		val, ok := m.Int(num)
+		return float64(val), ok
+	}
+}
+
+func (m Message) Scaled(num uint8, scale float64) (float64, bool) {
+	val, ok := m.Float(num)
+	return val / scale, ok
+}
+
+func (m Message) String(num uint8) (string, bool) {
+	field, ok := m.Fields[num]
+	if !ok || field.BaseType != baseString {
+		return "", false
+	}
+	res, _, _ := strings.Cut(string(field.Data), "\x00")
+	return res, res != ""
+}
+
+func (m Message) Time(num uint8) (time.Time, bool) {
+	val, ok := m.Uint(num)
+	if !ok {
+		return time.Time{}, false
+	}
+	return Time(uint32(val)), true
+}
+
+func (d *decoder) decodeFile(fn func(msg Message) error) error {
+	d.crc = 0
+	d.read = 0
+	d.defs = [16]*definition{}
+	d.lastTimestamp = 0
+
+	header := make([]byte, 1)
+	if err := d.readFull(header); err != nil {
+		return fmt.Errorf("error when reading FIT header: %v", err)
+	}
+	headerSize := int(header[0])
+	if headerSize < 12 {
+		return fmt.Errorf("invalid FIT header size %d", headerSize)
+	}
+	header = append(header, make([]byte, headerSize-1)...)
+	if err := d.readFull(header[1:12]); err != nil {
+		return fmt.Errorf("error when reading FIT header: %v", err)
+	}
+	headerCrc := d.crc
+	if err := d.readFull(header[12:]); err != nil {
+		return fmt.Errorf("error when reading FIT header: %v", err)
+	}
+	if string(header[8:12]) != ".FIT" {
+		return fmt.Errorf("invalid FIT signature %q", header[8:12])
+	}
+	if headerSize >= 14 {
+		if expected := binary.LittleEndian.Uint16(header[12:14]); expected != 0 && expected != headerCrc {
+			return fmt.Errorf("FIT header CRC mismatch: %04x != %04x", headerCrc, expected)
+		}
+	}
+
+	dataSize := binary.LittleEndian.Uint32(header[4:8])
+	for d.read = 0; d.read < dataSize; {
+		msg, err := d.readRecord()
+		if err != nil {
+			return fmt.Errorf("error when reading FIT record at %d: %v", headerSize+int(d.read), err)
+		}
+		if msg == nil {
+			continue
+		}
+		if err := fn(*msg); err != nil {
+			return err
+		}
+	}
+	if d.read != dataSize {
+		return fmt.Errorf("FIT data size mismatch: %d != %d", d.read, dataSize)
+	}
+
+	fileCrc := d.crc
+	crc := make([]byte, 2)
+	if _, err := io.ReadFull(d.r, crc); err != nil {
+		return fmt.Errorf("error when reading FIT CRC: %v", err)
+	}
+	if expected := binary.LittleEndian.Uint16(crc); expected != fileCrc {
+		return fmt.Errorf("FIT file CRC mismatch: %04x != %04x", fileCrc, expected)
+	}
+	return nil
+}
+
+func (d *decoder) readRecord() (*Message, error) {
+	header, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	if header&0x80 != 0 {
+		offset := uint32(header & 0x1f)
+		timestamp := d.lastTimestamp&^0x1f | offset
+		if offset < d.lastTimestamp&0x1f {
+			timestamp += 0x20
+		}
+		return d.readData(header>>5&0x03, &timestamp)
+	}
+
+	localNum := header & 0x0f
+	if header&0x40 != 0 {
+		return nil, d.readDefinition(localNum, header&0x20 != 0)
+	}
+	return d.readData(localNum, nil)
+}
+
+func (d *decoder) readDefinition(localNum byte, withDevFields bool) error {
+	buf := make([]byte, 5)
+	if err := d.readFull(buf); err != nil {
+		return err
+	}
+
+	def := &definition{order: binary.ByteOrder(binary.LittleEndian)}
+	if buf[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.num = def.order.Uint16(buf[2:4])
+
+	fields := make([]byte, 3*int(buf[4]))
+	if err := d.readFull(fields); err != nil {
+		return err
+	}
+	for i := 0; i < len(fields); i += 3 {
+		baseType := fields[i+2] & 0x1f
+		if _, ok := baseTypeSizes[baseType]; !ok {
+			return fmt.Errorf("unknown base type %#02x of field %d in message %d", baseType, fields[i], def.num)
+		}
+		def.fields = append(def.fields, fieldDefinition{
+			num:      fields[i],
+			size:     int(fields[i+1]),
+			baseType: baseType,
+		})
+	}
+
+	if withDevFields {
+		count, err := d.readByte()
+		if err != nil {
+			return err
+		}
+		devFields := make([]byte, 3*int(count))
+		if err := d.readFull(devFields); err != nil {
+			return err
+		}
+		for i := 0; i < len(devFields); i += 3 {
+			def.devSize += int(devFields[i+1])
+		}
+	}
+
+	d.defs[localNum] = def
+	return nil
+}
+
+func (d *decoder) readData(localNum byte, timestamp *uint32) (*Message, error) {
+	def := d.defs[localNum]
+	if def == nil {
+		return nil, fmt.Errorf("data message of undefined local type %d", localNum)
+	}
+
+	msg := &Message{Num: def.num, Fields: map[uint8]Field{}}
+	for _, fieldDef := range def.fields {
+		data := make([]byte, fieldDef.size)
+		if err := d.readFull(data); err != nil {
+			return nil, err
+		}
+		msg.Fields[fieldDef.num] = Field{BaseType: fieldDef.baseType, Data: data, order: def.order}
+	}
+	if def.devSize > 0 {
+		if err := d.readFull(make([]byte, def.devSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	if timestamp != nil {
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, *timestamp)
+		msg.Fields[TimestampField] = Field{BaseType: baseUint32, Data: data, order: binary.LittleEndian}
+	}
+	if val, ok := msg.Uint(TimestampField); ok {
+		d.lastTimestamp = uint32(val)
+	}
+	return msg, nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	buf := make([]byte, 1)
+	if err := d.readFull(buf); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+func (d *decoder) readFull(buf []byte) error {
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	d.read += uint32(len(buf))
+	for _, b := range buf {
+		d.crc = updateCrc(d.crc, b)
+	}
+	return nil
+}
+
+func updateCrc(crc uint16, b byte) uint16 {
+	tmp := crcTable[crc&0x0f]
+	crc = crc >> 4 & 0x0fff
+	crc = crc ^ tmp ^ crcTable[b&0x0f]
+	tmp = crcTable[crc&0x0f]
+	crc = crc >> 4 & 0x0fff
+	return crc ^ tmp ^ crcTable[b>>4&0x0f]
+}
@@ -0,0 +1,144 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builds a FIT file with a 14-byte header around the given records
+func buildFitFile(records ...[]byte) []byte {
+	data := bytes.Join(records, nil)
+
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20
+	binary.LittleEndian.PutUint16(header[2:4], 2132)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(data)))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], getCrc(header[:12]))
+
+	res := append(header, data...)
+	return binary.LittleEndian.AppendUint16(res, getCrc(res))
+}
+
+func getCrc(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = updateCrc(crc, b)
+	}
+	return crc
+}
+
+type testFieldDefinition struct {
+	num      byte
+	size     byte
+	baseType byte
+}
+
+func buildDefinition(localNum byte, mesgNum uint16, fields ...testFieldDefinition) []byte {
+	res := []byte{0x40 | localNum, 0, 0}
+	res = binary.LittleEndian.AppendUint16(res, mesgNum)
+	res = append(res, byte(len(fields)))
+	for _, field := range fields {
+		res = append(res, field.num, field.size, field.baseType)
+	}
+	return res
+}
+
+func decodeAll(data []byte) ([]Message, error) {
+	var res []Message
+	err := Decode(bytes.NewReader(data), func(msg Message) error {
+		res = append(res, msg)
+		return nil
+	})
+	return res, err
+}
+
+func getWeightScaleFile() []byte {
+	timestamp := binary.LittleEndian.AppendUint32(nil, 1000)
+	return buildFitFile(
+		buildDefinition(0, MesgWeightScale,
+			testFieldDefinition{TimestampField, 4, 0x86},
+			testFieldDefinition{0, 2, 0x84},
+		),
+		append(append([]byte{0x00}, timestamp...), binary.LittleEndian.AppendUint16(nil, 7250)...),
+		buildDefinition(1, MesgWeightScale, testFieldDefinition{0, 2, 0x84}),
+		// Compressed timestamp headers carry the last 5 bits of the time offset
+		append([]byte{0x80 | 1<<5 | 12}, binary.LittleEndian.AppendUint16(nil, 7240)...),
+		append([]byte{0x80 | 1<<5 | 4}, binary.LittleEndian.AppendUint16(nil, 0xffff)...),
+	)
+}
+
+func TestDecode(t *testing.T) {
+	msgs, err := decodeAll(getWeightScaleFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	}
+
+	expected := []struct {
+		time   uint32
+		weight float64
+		ok     bool
+	}{
+		{1000, 72.5, true},
+		{1004, 72.4, true},
+		{1028, 0, false},
+	}
+	for i, item := range expected {
+		msg := msgs[i]
+		if msg.Num != MesgWeightScale {
+			t.Errorf("message %d: expected number %d, got %d", i, MesgWeightScale, msg.Num)
+		}
+		if val, ok := msg.Time(TimestampField); !ok || !val.Equal(Time(item.time)) {
+			t.Errorf("message %d: expected time %v, got %v", i, Time(item.time), val)
+		}
+		if val, ok := msg.Scaled(0, 100); ok != item.ok || ok && val != item.weight {
+			t.Errorf("message %d: expected weight %v (%v), got %v (%v)", i, item.weight, item.ok, val, ok)
+		}
+	}
+
+	if val, _ := msgs[0].Time(TimestampField); !val.Equal(time.Date(1989, 12, 31, 0, 16, 40, 0, time.UTC)) {
+		t.Errorf("unexpected FIT epoch time %v", val)
+	}
+}
+
+func TestDecodeCrcMismatch(t *testing.T) {
+	data := getWeightScaleFile()
+	data[len(data)-1] ^= 0xff
+
+	_, err := decodeAll(data)
+	if err == nil || !strings.Contains(err.Error(), "FIT file CRC mismatch") {
+		t.Fatalf("expected CRC mismatch, got %v", err)
+	}
+}
+
+func TestDecodeUnknownBaseType(t *testing.T) {
+	data := buildFitFile(
+		buildDefinition(0, MesgWeightScale, testFieldDefinition{0, 2, 0x11}),
+		[]byte{0x00, 0x01, 0x02},
+	)
+
+	_, err := decodeAll(data)
+	if err == nil || !strings.Contains(err.Error(), "unknown base type 0x11") {
+		t.Fatalf("expected unknown base type, got %v", err)
+	}
+}
+
+func TestMessageUnknownBaseType(t *testing.T) {
+	msg := Message{Fields: map[uint8]Field{0: {BaseType: 0x1f, Data: []byte{1, 2}, order: binary.LittleEndian}}}
+	if val, ok := msg.Uint(0); ok {
+		t.Errorf("expected invalid uint, got %d", val)
+	}
+	if val, ok := msg.Int(0); ok {
+		t.Errorf("expected invalid int, got %d", val)
+	}
+	if val, ok := msg.Float(0); ok {
+		t.Errorf("expected invalid float, got %v", val)
+	}
+}
@@ -0,0 +1,374 @@
+package import_util
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre487/data-migrators/providers/fatsecret"
+	"github.com/andre487/data-migrators/utils/misc"
+)
+
+const PoundKg = 0.45359237
+
+var DefaultDateLayouts = []string{time.DateOnly, time.DateTime, time.RFC3339, "2006-01-02 15:04:05 -0700", "1/2/2006", "01/02/2006"}
+
+type NutrientColumn struct {
+	Nutrient string
+	Columns  []string
+	Scale    float64
+}
+
+func SyntheticId(parts ...string) int64 {
+	hash := fnv.New64a()
+	for _, part := range parts {
+		_, _ = hash.Write([]byte(part))
+		_, _ = hash.Write([]byte{0})
+	}
+	return int64(hash.Sum64()&(1<<62-1) | 1<<62)
+}
+
+func NormalizeMeal(meal string) string {
+	switch strings.ToLower(strings.TrimSpace(meal)) {
+	case "breakfast":
+		return "Breakfast"
+	case "lunch":
+		return "Lunch"
+	case "dinner", "supper":
+		return "Dinner"
+	default:
+		return "Other"
+	}
+}
+
+func WeightUnitScale(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "", "kg":
+		return 1, nil
+	case "lb", "lbs":
+		return PoundKg, nil
+	default:
+		return 0, fmt.Errorf("unknown weight unit %s", unit)
+	}
+}
+
+func DayDate(date time.Time) (int64, time.Time) {
+	res := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return misc.DateToDaysFromEpoch(res), res
+}
+
+func ParseDate(val string, layouts ...string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = DefaultDateLayouts
+	}
+	val = strings.TrimSpace(val)
+	for _, layout := range layouts {
+		if res, err := time.Parse(layout, val); err == nil {
+			return res, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format: %s", val)
+}
+
+func OpenArchive(archivePath string) (fs.FS, func() error, error) {
+	stat, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when opening archive: %v", err)
+	}
+	if stat.IsDir() {
+		return os.DirFS(archivePath), func() error { return nil }, nil
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when opening ZIP archive %s: %v", archivePath, err)
+	}
+	return reader, reader.Close, nil
+}
+
+func FindFiles(fsys fs.FS, match func(name string) bool) ([]string, error) {
+	var res []string
+	err := fs.WalkDir(fsys, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && match(strings.ToLower(path.Base(filePath))) {
+			res = append(res, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing archive files: %v", err)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+type CsvRecord struct {
+	Line    int
+	header  []string
+	columns map[string]int
+	values  []string
+}
+
+func (r CsvRecord) Has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := r.columns[normalizeColumn(name)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r CsvRecord) Get(names ...string) string {
+	for _, name := range names {
+		if idx, ok := r.columns[normalizeColumn(name)]; ok && idx < len(r.values) {
+			return strings.TrimSpace(r.values[idx])
+		}
+	}
+	return ""
+}
+
+func (r CsvRecord) Float(names ...string) (float64, bool, error) {
+	val := NormalizeNumber(r.Get(names...))
+	if val == "" {
+		return 0, false, nil
+	}
+	res, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("line %d: invalid number %s in %s: %v", r.Line, val, names[0], err)
+	}
+	return res, true, nil
+}
+
+func (r CsvRecord) Columns() []string {
+	return r.header
+}
+
+func ReadCsv(r io.Reader, comma rune, fn func(rec CsvRecord) error) error {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error when reading CSV header: %v", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		header[i] = strings.TrimSpace(name)
+		columns[normalizeColumn(name)] = i
+	}
+
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error when reading CSV line %d: %v", line, err)
+		}
+		if err := fn(CsvRecord{Line: line, header: header, columns: columns, values: values}); err != nil {
+			return err
+		}
+	}
+}
+
+func ReadCsvFile(fsys fs.FS, filePath string, comma rune, fn func(rec CsvRecord) error) error {
+	fp, err := fsys.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error when opening %s: %v", filePath, err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	if err := ReadCsv(fp, comma, fn); err != nil {
+		return fmt.Errorf("%s: %v", filePath, err)
+	}
+	return nil
+}
+
+func ReadNutrients(rec CsvRecord, entry *fatsecret.FoodEntryData, columns []NutrientColumn) error {
+	for _, col := range columns {
+		val, ok, err := rec.Float(col.Columns...)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			entry.MissingNutrients = append(entry.MissingNutrients, col.Nutrient)
+			continue
+		}
+		if col.Scale != 0 {
+			val *= col.Scale
+		}
+		*entry.NutrientField(col.Nutrient) = val
+	}
+	return nil
+}
+
+// The last of "." and "," is the decimal separator when both are present, a single "," is a decimal comma
+func NormalizeNumber(val string) string {
+	dot := strings.LastIndex(val, ".")
+	comma := strings.LastIndex(val, ",")
+	switch {
+	case comma < 0:
+		return val
+	case dot > comma:
+		return strings.ReplaceAll(val, ",", "")
+	case dot >= 0:
+		return strings.Replace(strings.ReplaceAll(val, ".", ""), ",", ".", 1)
+	case strings.Count(val, ",") > 1:
+		return strings.ReplaceAll(val, ",", "")
+	default:
+		return strings.Replace(val, ",", ".", 1)
+	}
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+type DiaryBuilder struct {
+	FromDate  time.Time
+	ToDate    time.Time
+	days      map[int64]fatsecret.FoodEntryDayData
+	entries   []fatsecret.FoodEntryData
+	weights   []fatsecret.WeightData
+	exercises []fatsecret.ExerciseData
+}
+
+func NewDiaryBuilder(fromDate time.Time, toDate time.Time) *DiaryBuilder {
+	return &DiaryBuilder{FromDate: fromDate, ToDate: toDate, days: map[int64]fatsecret.FoodEntryDayData{}}
+}
+
+func (b *DiaryBuilder) InRange(date time.Time) bool {
+	_, day := DayDate(date)
+	if !b.FromDate.IsZero() && day.Before(b.FromDate) {
+		return false
+	}
+	if !b.ToDate.IsZero() && day.After(b.ToDate) {
+		return false
+	}
+	return true
+}
+
+func (b *DiaryBuilder) SetDay(day fatsecret.FoodEntryDayData) {
+	if !b.InRange(day.Date) {
+		return
+	}
+	day.DateInt, day.Date = DayDate(day.Date)
+	b.days[day.DateInt] = day
+}
+
+func (b *DiaryBuilder) AddEntry(entry fatsecret.FoodEntryData) {
+	if !b.InRange(entry.Date) {
+		return
+	}
+	entry.DateInt, entry.Date = DayDate(entry.Date)
+	b.entries = append(b.entries, entry)
+}
+
+func (b *DiaryBuilder) AddWeight(weight fatsecret.WeightData) {
+	if !b.InRange(weight.Date) {
+		return
+	}
+	weight.DateInt, _ = DayDate(weight.Date)
+	b.weights = append(b.weights, weight)
+}
+
+func (b *DiaryBuilder) AddExercise(exercise fatsecret.ExerciseData) {
+	if !b.InRange(exercise.Date) {
+		return
+	}
+	exercise.DateInt, _ = DayDate(exercise.Date)
+	b.exercises = append(b.exercises, exercise)
+}
+
+func (b *DiaryBuilder) Build() *fatsecret.DiaryData {
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].DateInt < b.entries[j].DateInt
+	})
+	sort.SliceStable(b.weights, func(i, j int) bool {
+		return b.weights[i].Date.Before(b.weights[j].Date)
+	})
+	sort.SliceStable(b.exercises, func(i, j int) bool {
+		return b.exercises[i].Date.Before(b.exercises[j].Date)
+	})
+
+	res := &fatsecret.DiaryData{
+		DiaryData: b.entries,
+		Weights:   b.weights,
+		Exercises: b.exercises,
+	}
+	for _, entry := range b.entries {
+		last := len(res.AggregatedDayData) - 1
+		if last < 0 || res.AggregatedDayData[last].DateInt != entry.DateInt {
+			res.AggregatedDayData = append(res.AggregatedDayData, fatsecret.FoodEntryDayData{DateInt: entry.DateInt, Date: entry.Date})
+			last++
+		}
+		day := &res.AggregatedDayData[last]
+		day.Calories += entry.Calories
+		day.Carbohydrate += entry.Carbohydrate
+		day.Fat += entry.Fat
+		day.Protein += entry.Protein
+	}
+
+	if len(b.days) > 0 {
+		aggregated := map[int64]bool{}
+		for i, day := range res.AggregatedDayData {
+			if setDay, ok := b.days[day.DateInt]; ok {
+				res.AggregatedDayData[i] = setDay
+				aggregated[day.DateInt] = true
+			}
+		}
+		for dateInt, day := range b.days {
+			if !aggregated[dateInt] {
+				res.AggregatedDayData = append(res.AggregatedDayData, day)
+			}
+		}
+		sort.Slice(res.AggregatedDayData, func(i, j int) bool {
+			return res.AggregatedDayData[i].DateInt < res.AggregatedDayData[j].DateInt
+		})
+	}
+
+	var dates []time.Time
+	for _, day := range res.AggregatedDayData {
+		dates = append(dates, day.Date)
+	}
+	for _, weight := range res.Weights {
+		_, day := DayDate(weight.Date)
+		dates = append(dates, day)
+	}
+	for _, exercise := range res.Exercises {
+		_, day := DayDate(exercise.Date)
+		dates = append(dates, day)
+	}
+	for _, date := range dates {
+		if res.FromDate.IsZero() || date.Before(res.FromDate) {
+			res.FromDate = date
+		}
+		if date.After(res.ToDate) {
+			res.ToDate = date
+		}
+	}
+	return res
+}
@@ -4,10 +4,30 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/loynoir/ExpandUser.go"
 )
 
+func GetSecret(ref string) ([]byte, error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return []byte{}, fmt.Errorf("secret reference is empty")
+	case strings.HasPrefix(ref, "{") || strings.HasPrefix(ref, "["):
+		return []byte(ref), nil
+	case strings.HasPrefix(ref, "file:"):
+		return GetSecretFromFile(strings.TrimPrefix(ref, "file:"))
+	case strings.HasPrefix(ref, "env:"):
+		return GetSecretFromEnv(strings.TrimPrefix(ref, "env:"))
+	case strings.HasPrefix(ref, "cmd:"):
+		return GetSecretFromCommand(strings.TrimPrefix(ref, "cmd:"))
+	default:
+		return GetSecretFromFile(ref)
+	}
+}
+
 func GetSecretFromFile(path string) ([]byte, error) {
 	var err error
 	if path, err = ExpandUser.ExpandUser(path); err != nil {
@@ -21,3 +41,28 @@ func GetSecretFromFile(path string) ([]byte, error) {
 
 	return bytes.TrimSpace(rawContent), nil
 }
+
+func GetSecretFromEnv(name string) ([]byte, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return []byte{}, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return bytes.TrimSpace([]byte(val)), nil
+}
+
+func GetSecretFromCommand(command string) ([]byte, error) {
+	if strings.TrimSpace(command) == "" {
+		return []byte{}, fmt.Errorf("secret command is empty")
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	rawContent, err := cmd.Output()
+	if err != nil {
+		return []byte{}, fmt.Errorf("secret command `%s` error: %v", command, err)
+	}
+
+	return bytes.TrimSpace(rawContent), nil
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/loynoir/ExpandUser.go"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const secretFileMagic = "DM487ENC1"
+const secretSaltSize = 16
+const secretNonceSize = 24
+
+func (s *Storage) ReadSecretFile(name string) ([]byte, error) {
+	filePath, err := s.GetFile(name, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading secret file: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if !bytes.HasPrefix(data, []byte(secretFileMagic)) {
+		log.Printf("Storage: encrypting plaintext secret file %s\n", filePath)
+		if err := s.WriteSecretFile(name, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	data = data[len(secretFileMagic):]
+	if len(data) < secretSaltSize+secretNonceSize {
+		return nil, fmt.Errorf("secret file %s is truncated", filePath)
+	}
+	salt := data[:secretSaltSize]
+	var nonce [secretNonceSize]byte
+	copy(nonce[:], data[secretSaltSize:secretSaltSize+secretNonceSize])
+
+	key, err := s.getSecretKey(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := secretbox.Open(nil, data[secretSaltSize+secretNonceSize:], &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("error when decrypting secret file %s: wrong key or corrupted data", filePath)
+	}
+	return res, nil
+}
+
+func (s *Storage) WriteSecretFile(name string, data []byte) error {
+	filePath, err := s.GetFile(name, 0600)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, secretSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("error when generating salt: %v", err)
+	}
+	var nonce [secretNonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return fmt.Errorf("error when generating nonce: %v", err)
+	}
+
+	key, err := s.getSecretKey(salt)
+	if err != nil {
+		return err
+	}
+
+	res := []byte(secretFileMagic)
+	res = append(res, salt...)
+	res = append(res, nonce[:]...)
+	res = secretbox.Seal(res, data, &nonce, key)
+
+	if err := os.WriteFile(filePath, res, 0600); err != nil {
+		return fmt.Errorf("error when writing secret file: %v", err)
+	}
+	return nil
+}
+
+func (s *Storage) MigrateSecretFiles(pattern string) error {
+	filePaths, err := filepath.Glob(path.Join(s.baseDir, s.namespace, pattern))
+	if err != nil {
+		return fmt.Errorf("invalid secret files pattern: %v", err)
+	}
+
+	for _, filePath := range filePaths {
+		name, err := filepath.Rel(path.Join(s.baseDir, s.namespace), filePath)
+		if err != nil {
+			return err
+		}
+		if _, err := s.ReadSecretFile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) getSecretKey(salt []byte) (*[32]byte, error) {
+	if key, ok := s.secretKeys[string(salt)]; ok {
+		return key, nil
+	}
+
+	material, err := s.getSecretKeyMaterial()
+	if err != nil {
+		return nil, err
+	}
+
+	rawKey, err := scrypt.Key(material, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error when deriving secret key: %v", err)
+	}
+
+	key := new([32]byte)
+	copy(key[:], rawKey)
+	if s.secretKeys == nil {
+		s.secretKeys = map[string]*[32]byte{}
+	}
+	s.secretKeys[string(salt)] = key
+	return key, nil
+}
+
+func (s *Storage) getSecretKeyMaterial() ([]byte, error) {
+	if passphrase := os.Getenv("DM_SECRET_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	keyFile := os.Getenv("DM_SECRET_KEY_FILE")
+	if keyFile == "" {
+		keyFile = path.Join(s.baseDir, "secret.key")
+		if err := createSecretKeyFile(keyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	keyFile, err := ExpandUser.ExpandUser(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key file path: %v", err)
+	}
+
+	material, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading secret key file: %v", err)
+	}
+	material = bytes.TrimSpace(material)
+	if len(material) == 0 {
+		return nil, fmt.Errorf("secret key file %s is empty", keyFile)
+	}
+	return material, nil
+}
+
+func createSecretKeyFile(keyFile string) error {
+	if _, err := os.Stat(keyFile); !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	rawKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, rawKey); err != nil {
+		return fmt.Errorf("error when generating secret key: %v", err)
+	}
+
+	if err := os.MkdirAll(path.Dir(keyFile), 0700); err != nil {
+		return fmt.Errorf("error when creating a directory: %v", err)
+	}
+	content := []byte(base64.StdEncoding.EncodeToString(rawKey) + "\n")
+	if err := os.WriteFile(keyFile, content, 0600); err != nil {
+		return fmt.Errorf("error when writing secret key file: %v", err)
+	}
+	log.Printf("Storage: secret key file was created: %s\n", keyFile)
+	return nil
+}
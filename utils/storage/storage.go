@@ -2,6 +2,7 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"os"
@@ -11,11 +12,12 @@ import (
 )
 
 type Storage struct {
-	baseDir   string
-	namespace string
+	baseDir    string
+	namespace  string
+	secretKeys map[string]*[32]byte
 }
 
-func New(namespace string) *Storage {
+func New(namespace string) (*Storage, error) {
 	var baseDir string
 	if envBaseDir := os.Getenv("DM_BASE_DIR"); envBaseDir != "" {
 		baseDir = envBaseDir
@@ -29,34 +31,34 @@ func New(namespace string) *Storage {
 
 	baseDir, err := ExpandUser.ExpandUser(baseDir)
 	if err != nil {
-		log.Fatalf("invalid base dir: %v", err)
+		return nil, fmt.Errorf("invalid base dir: %v", err)
 	}
 
-	return &Storage{baseDir: baseDir, namespace: namespace}
+	return &Storage{baseDir: baseDir, namespace: namespace}, nil
 }
 
-func (s *Storage) GetDir(name string, fileMode fs.FileMode) string {
+func (s *Storage) GetDir(name string, fileMode fs.FileMode) (string, error) {
 	curDir := path.Join(s.baseDir, s.namespace, name)
 	if err := os.MkdirAll(curDir, fileMode); err != nil {
-		log.Fatalf("error when creating a directory: %v", err)
+		return "", fmt.Errorf("error when creating a directory: %v", err)
 	}
-	return curDir
+	return curDir, nil
 }
 
-func (s *Storage) GetFile(name string, fileMode fs.FileMode) string {
+func (s *Storage) GetFile(name string, fileMode fs.FileMode) (string, error) {
 	curFile := path.Join(s.baseDir, s.namespace, name)
 	if err := os.MkdirAll(path.Dir(curFile), 0755); err != nil {
-		log.Fatalf("error when creating a directory: %v", err)
+		return "", fmt.Errorf("error when creating a directory: %v", err)
 	}
 
 	if _, err := os.Stat(curFile); errors.Is(err, os.ErrNotExist) {
 		fp, err := os.OpenFile(curFile, os.O_RDONLY|os.O_CREATE, fileMode)
 		if err != nil {
-			log.Fatalf("error when creating storage file: %v", err)
+			return "", fmt.Errorf("error when creating storage file: %v", err)
 		}
 		if err := fp.Close(); err != nil {
 			log.Printf("WARN: error when closing file: %v", err)
 		}
 	}
-	return curFile
+	return curFile, nil
 }